@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -17,28 +18,107 @@ var builderPool = sync.Pool{
 }
 
 type SQLBulder struct {
-	tableName      string
-	action         SQLAction
-	fields         []string
-	args           []interface{}
-	whereList      Wheres
-	orders         Orders
-	groups         Groups
-	limitSize      Limit
-	offsetSize     Offset
-	parameterized  bool
-	delimiter      string
-	values         Values
-	forceIndexName ForceIndex
-	insert         *Insert
-	reuse          bool
+	tableName     string
+	action        SQLAction
+	distinct      bool
+	fields        []interface{}
+	args          []interface{}
+	whereList     Wheres
+	havingList    Wheres
+	orders        Orders
+	groups        Groups
+	limitSize     Limit
+	offsetSize    Offset
+	parameterized bool
+	dialect       Dialect
+	// allowUnknownOps turns the strict operator check off
+	allowUnknownOps bool
+	values          Values
+	columns         []string
+	forceIndexName  ForceIndex
+	fromSub         *SQLBulder
+	joins           Joins
+	lock            LockMode
+	compounds       []*Compound
+	ctes            []*CTE
+	windows         namedWindows
+	insert          *Insert
+	reuse           bool
+	immutable       bool
+	released        bool
+	errs            []error
 }
 
 type Insert struct {
 	onDupKeyUpdates onDupKeyUpdates
 	ignore          bool
+	batchMode       BatchMode
+	chunk           ChunkLimits
+	conflict        onConflict
+}
+
+// onConflict holds the parts of an upsert besides the columns overwritten
+// with the inserted values, which are onDupKeyUpdates.
+type onConflict struct {
+	columns    []string
+	constraint string
+	doNothing  bool
+	set        map[string]interface{}
+	where      Wheres
+}
+
+func (c onConflict) clone() onConflict {
+	cloned := c
+	cloned.columns = append([]string(nil), c.columns...)
+	if c.set != nil {
+		cloned.set = make(map[string]interface{}, len(c.set))
+		for col, value := range c.set {
+			cloned.set[col] = value
+		}
+	}
+	cloned.where = c.where.clone()
+	return cloned
+}
+
+// ChunkLimits splits a batch insert into several statements, see BuildAll.
+// A zero limit is no limit, a row over the limits is inserted on its own.
+// MySQL and PostgreSQL bind at most 65535 placeholders in a statement,
+// SQLite 32766 and SQL Server 2100.
+type ChunkLimits struct {
+	MaxRows         int
+	MaxPlaceholders int
+	// MaxBytes bounds the estimated size of a statement and its args,
+	// keep it below max_allowed_packet on mysql.
+	MaxBytes int
+}
+
+// BatchMode decides how a batch insert handles rows which do not have the
+// same columns.
+type BatchMode string
+
+const (
+	// BatchStrict fails the build with ErrInconsistentBatchColumns.
+	BatchStrict BatchMode = ""
+	// BatchFillDefault inserts every column of any row, filling the ones
+	// a row is missing with DEFAULT.
+	BatchFillDefault BatchMode = "DEFAULT"
+	// BatchFillNull inserts every column of any row, filling the ones a
+	// row is missing with NULL.
+	BatchFillNull BatchMode = "NULL"
+	// BatchGroup inserts the rows with the same columns together, one
+	// statement per set of columns, see BuildAll.
+	BatchGroup BatchMode = "GROUP"
+)
+
+// Statement is a built sql statement with its args.
+type Statement struct {
+	SQL  string
+	Args []interface{}
 }
 
+// UpdateRaw sets a column to Expr followed by Args. A parameterized builder
+// binds Args to the ? placeholders of Expr like an Expr, otherwise Expr is
+// written verbatim.
 type UpdateRaw struct {
 	Expr string
 	Args []interface{}
@@ -74,6 +154,66 @@ func (f ForceIndex) String() string {
 	return ""
 }
 
+// LockMode is the locking read applied to the rows of a select.
+type LockMode string
+
+const (
+	LockForUpdate LockMode = "FOR UPDATE"
+	LockForShare  LockMode = "FOR SHARE"
+)
+
+type CompoundOp string
+
+const (
+	CompoundUnion     CompoundOp = "UNION"
+	CompoundUnionAll  CompoundOp = "UNION ALL"
+	CompoundIntersect CompoundOp = "INTERSECT"
+	CompoundExcept    CompoundOp = "EXCEPT"
+)
+
+// Compound combines the result of Query with the select it is added to.
+type Compound struct {
+	Op    CompoundOp
+	Query *SQLBulder
+}
+
+// CTE is a common table expression, the main query can use Name as a
+// table. A recursive CTE unions Recursive, which refers to Name, with the
+// anchor Query.
+type CTE struct {
+	Name      string
+	Columns   []string
+	Query     *SQLBulder
+	Recursive *SQLBulder
+}
+
+func (cte *CTE) string(st *stmt) (string, []interface{}, error) {
+	sql, args, err := st.nested(cte.Query)
+	if err != nil {
+		return "", nil, err
+	}
+	if cte.Recursive != nil {
+		recursive, recursiveArgs, err := st.nested(cte.Recursive)
+		if err != nil {
+			return "", nil, err
+		}
+		sql = sql + " " + string(CompoundUnionAll) + " " + recursive
+		args = append(args, recursiveArgs...)
+	}
+	name, err := quoteIdent(st.dialect, cte.Name)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(cte.Columns) > 0 {
+		columns, err := quoteIdents(st.dialect, cte.Columns)
+		if err != nil {
+			return "", nil, err
+		}
+		name += _wrapBracket(columns)
+	}
+	return name + " AS " + _wrapBracket(sql), args, nil
+}
+
 type Values []map[string]interface{}
 
 type SQLAction string
@@ -85,24 +225,130 @@ const (
 	SQLActionInsert SQLAction = "insert"
 )
 
-type Groups []string
+// Groups holds column expressions, or Raw expressions.
+type Groups []interface{}
 
 func (groups Groups) String() string {
 	if len(groups) == 0 {
 		return ""
 	}
-	return "GROUP BY " + strings.Join(groups, ",")
+	resList := make([]string, 0, len(groups))
+	for _, group := range groups {
+		resList = append(resList, fmt.Sprint(group))
+	}
+	return "GROUP BY " + strings.Join(resList, ",")
 }
 
+func (groups Groups) string(st *stmt) (string, error) {
+	if len(groups) == 0 {
+		return "", nil
+	}
+	resList := make([]string, 0, len(groups))
+	for _, group := range groups {
+		str, err := st.expr(group)
+		if err != nil {
+			return "", err
+		}
+		resList = append(resList, str)
+	}
+	return "GROUP BY " + strings.Join(resList, ","), nil
+}
+
+type JoinType string
+
+const (
+	JoinInner JoinType = "INNER JOIN"
+	JoinLeft  JoinType = "LEFT JOIN"
+	JoinRight JoinType = "RIGHT JOIN"
+	JoinCross JoinType = "CROSS JOIN"
+)
+
+// Join joins Table, which may carry an alias like "orders o", on the
+// conditions in On. A cross join has no conditions.
+type Join struct {
+	Type  JoinType
+	Table string
+	On    Wheres
+}
+
+func (j *Join) string(st *stmt) (string, []interface{}, error) {
+	table, err := quoteTable(st.dialect, j.Table)
+	if err != nil {
+		return "", nil, err
+	}
+	on, args, err := j.On.string(false, st)
+	if err != nil || on == "" {
+		return string(j.Type) + " " + table, args, err
+	}
+	return string(j.Type) + " " + table + " ON " + on, args, nil
+}
+
+type Joins []*Join
+
+func (joins Joins) string(st *stmt) (string, []interface{}, error) {
+	if len(joins) == 0 {
+		return "", make([]interface{}, 0), nil
+	}
+	resList := make([]string, 0, len(joins))
+	joinArgs := make([]interface{}, 0)
+	for _, j := range joins {
+		str, args, err := j.string(st)
+		if err != nil {
+			return "", nil, err
+		}
+		resList = append(resList, str)
+		joinArgs = append(joinArgs, args...)
+	}
+	return strings.Join(resList, " "), joinArgs, nil
+}
+
+// Column refers to a column on the right hand side of a condition, so that
+// two columns can be compared, e.g. in the ON conditions of a join.
+type Column string
+
+func Col(name string) Column {
+	return Column(name)
+}
+
+// Excluded refers to a column of the row an upsert proposed for insertion,
+// in the values of DoUpdateSet and DoUpdateWhere.
+type Excluded string
+
+// Order sorts by the column expression Field, by the expression Field
+// verbatim when Raw is set, or by Expr when it is not nil.
 type Order struct {
 	Order OrderEnum
 	Field string
+	Raw   bool
+	Expr  *Expr
 }
 
 func (o *Order) String() string {
 	return o.Field + " " + string(o.Order)
 }
 
+func (o *Order) string(st *stmt) (string, []interface{}, error) {
+	field, args := o.Field, []interface{}(nil)
+	var err error
+	if o.Expr != nil {
+		if field, args, err = st.bind(*o.Expr); err != nil {
+			return "", nil, err
+		}
+	} else if !o.Raw {
+		if field, err = quoteColumn(st.dialect, o.Field, false); err != nil {
+			return "", nil, err
+		}
+	}
+	if o.Order == "" {
+		return field, args, nil
+	}
+	order := OrderEnum(strings.ToUpper(string(o.Order)))
+	if order != OrderAsc && order != OrderDesc {
+		return "", nil, _wrapError(ErrInvalidExpression, "invalid order "+string(o.Order))
+	}
+	return field + " " + string(order), args, nil
+}
+
 type Orders []*Order
 
 func (orders Orders) String() string {
@@ -119,6 +365,23 @@ func (orders Orders) String() string {
 	return orderBySb.String()
 }
 
+func (orders Orders) string(st *stmt) (string, []interface{}, error) {
+	if len(orders) == 0 {
+		return "", nil, nil
+	}
+	resList := make([]string, 0, len(orders))
+	args := make([]interface{}, 0)
+	for _, o := range orders {
+		str, orderArgs, err := o.string(st)
+		if err != nil {
+			return "", nil, err
+		}
+		resList = append(resList, str)
+		args = append(args, orderArgs...)
+	}
+	return "ORDER BY " + strings.Join(resList, ","), args, nil
+}
+
 type OrderEnum string
 
 const (
@@ -154,6 +417,25 @@ const (
 	OpGte   Operation = ">="
 	OpIn    Operation = "in"
 	OpNotIn Operation = "not in"
+
+	OpExists    Operation = "exists"
+	OpNotExists Operation = "not exists"
+
+	OpIsNull    Operation = "is null"
+	OpIsNotNull Operation = "is not null"
+
+	OpNe         Operation = "!="
+	OpLike       Operation = "like"
+	OpNotLike    Operation = "not like"
+	OpBetween    Operation = "between"
+	OpNotBetween Operation = "not between"
+	OpRegexp     Operation = "regexp"
+
+	// OpNullSafeEq is an equality that holds when both sides are NULL, the
+	// same as OpIsNotDistinctFrom.
+	OpNullSafeEq        Operation = "<=>"
+	OpIsDistinctFrom    Operation = "is distinct from"
+	OpIsNotDistinctFrom Operation = "is not distinct from"
 )
 
 func (o Operation) lower() Operation {
@@ -167,7 +449,10 @@ const (
 	WhereCondOr  WhereCond = "OR"
 )
 
+// Where is a condition on Field, or the raw condition Expr when it is not
+// nil.
 type Where struct {
+	Expr         *Expr
 	Field        string
 	Operation    Operation
 	Value        []interface{}
@@ -230,52 +515,99 @@ func (wh *Where) Wheres(fn GetWhereFn) *Where {
 	return wh
 }
 
-func (wh *Where) string(first, needBracket bool, getPh func(typeKind reflect.Kind) string) (string, []interface{}) {
+func (wh *Where) string(first, needBracket bool, st *stmt) (string, []interface{}, error) {
 	if len(wh.Children) > 0 {
-		s, args := wh.Children.string(needBracket, getPh)
+		s, args, err := wh.Children.string(needBracket, st)
+		if err != nil || first {
+			return s, args, err
+		}
+		return string(wh.Cond) + " " + s, args, nil
+	}
+	if wh.Expr != nil {
+		cond, values, err := st.bind(*wh.Expr)
+		if err != nil {
+			return "", nil, err
+		}
+		// the raw condition may hold an OR of its own
+		cond = _wrapBracket(cond)
 		if first {
-			return s, args
+			return cond, values, nil
 		}
-		return string(wh.Cond) + " " + s, args
+		return string(wh.Cond) + " " + cond, values, nil
 	}
-	ph := getPh(reflect.TypeOf(wh.Value[0]).Kind())
-	phs := ph
 	field := wh.Field
 	values := wh.Value
+	var operands []string
+
+	if field != "" && wh.CombineRight == nil {
+		var err error
+		if field, err = quoteColumn(st.dialect, field, false); err != nil {
+			return "", nil, err
+		}
+	}
 
-	if wh.Operation == OpIn || wh.Operation == OpNotIn {
+	if wh.Operation == OpIsNull || wh.Operation == OpIsNotNull {
+		values = nil
+	} else if len(values) == 0 {
+		// e.g. the bounds of a between given as an empty slice
+		return "", nil, _wrapError(ErrInvalidExpression, fmt.Sprintf("%s %s has no values", wh.Field, wh.Operation))
+	} else if sub, ok := values[0].(*SQLBulder); ok {
+		subSQL, subArgs, err := st.subquery(sub)
+		if err != nil {
+			return "", nil, err
+		}
+		operands = []string{subSQL}
+		values = subArgs
+	} else if wh.Operation == OpIn || wh.Operation == OpNotIn {
 		if wh.CombineRight == nil {
-			phs = _wrapBracket(_stringRepeatJoin(ph, ",", len(wh.Value)))
+			var list string
+			list, values = st.values(values)
+			operands = []string{_wrapBracket(list)}
 		} else {
 			combineFields := []string{}
 			cur := wh
 			allValues := make([][]interface{}, 0)
-			phsSb := strings.Builder{}
 			for cur != nil {
-				combineFields = append(combineFields, cur.Field)
-				phsSb.WriteString(getPh(reflect.TypeOf(cur.Value[0]).Kind()))
+				combineField, err := quoteName(st.dialect, cur.Field)
+				if err != nil {
+					return "", nil, err
+				}
+				combineFields = append(combineFields, combineField)
 				allValues = append(allValues, cur.Value)
 				cur = cur.CombineRight
-				if cur != nil {
-					phsSb.WriteString(",")
-				}
 			}
 			field = _wrapBracket(strings.Join(combineFields, ","))
-			phs = _wrapBracket(_stringRepeatJoin(_wrapBracket(phsSb.String()), ",", len(wh.Value)))
 
+			// placeholders are generated tuple by tuple so that numbered
+			// placeholders follow the order of the flattened values.
 			values = make([]interface{}, 0, len(allValues)*len(allValues[0]))
+			tuples := make([]string, 0, len(allValues[0]))
 			for i := 0; i < len(allValues[0]); i++ {
+				tuple := make([]interface{}, 0, len(allValues))
 				for j := 0; j < len(allValues); j++ {
-					values = append(values, allValues[j][i])
+					tuple = append(tuple, allValues[j][i])
 				}
+				list, tupleArgs := st.values(tuple)
+				tuples = append(tuples, _wrapBracket(list))
+				values = append(values, tupleArgs...)
 			}
+			operands = []string{_wrapBracket(strings.Join(tuples, ","))}
+		}
+	} else {
+		var err error
+		if operands, values, err = st.operands(values); err != nil {
+			return "", nil, err
 		}
 	}
 
+	cond, err := _renderCondition(st.dialect, field, wh.Operation, operands, st.allowUnknownOps)
+	if err != nil {
+		return "", nil, err
+	}
 	if first {
-		return strings.Join([]string{field, string(wh.Operation), phs}, " "), values
+		return cond, values, nil
 	}
-	return strings.Join([]string{string(wh.Cond), field, string(wh.Operation), phs}, " "), values
+	return string(wh.Cond) + " " + cond, values, nil
 }
 
 type Wheres []*Where
@@ -301,7 +633,7 @@ func (whs Wheres) findByField(field string) []*Where {
 }
 
 func (whs Wheres) Where(field string, operation Operation, value interface{}) Wheres {
-	return whs.where(field, operation, []interface{}{value}, WhereCondAnd)
+	return whs.where(field, operation, _whereValues(operation, value), WhereCondAnd)
 }
 
 func (whs Wheres) WhereIn(field string, value []interface{}) Wheres {
@@ -320,6 +652,38 @@ func (whs Wheres) OrWhereNotIn(field string, value []interface{}) Wheres {
 	return whs.where(field, OpNotIn, value, WhereCondOr)
 }
 
+func (whs Wheres) WhereNull(field string) Wheres {
+	return whs.where(field, OpIsNull, nil, WhereCondAnd)
+}
+
+func (whs Wheres) OrWhereNull(field string) Wheres {
+	return whs.where(field, OpIsNull, nil, WhereCondOr)
+}
+
+func (whs Wheres) WhereNotNull(field string) Wheres {
+	return whs.where(field, OpIsNotNull, nil, WhereCondAnd)
+}
+
+func (whs Wheres) OrWhereNotNull(field string) Wheres {
+	return whs.where(field, OpIsNotNull, nil, WhereCondOr)
+}
+
+func (whs Wheres) WhereExists(sub *SQLBulder) Wheres {
+	return whs.where("", OpExists, []interface{}{sub}, WhereCondAnd)
+}
+
+func (whs Wheres) OrWhereExists(sub *SQLBulder) Wheres {
+	return whs.where("", OpExists, []interface{}{sub}, WhereCondOr)
+}
+
+func (whs Wheres) WhereNotExists(sub *SQLBulder) Wheres {
+	return whs.where("", OpNotExists, []interface{}{sub}, WhereCondAnd)
+}
+
+func (whs Wheres) OrWhereNotExists(sub *SQLBulder) Wheres {
+	return whs.where("", OpNotExists, []interface{}{sub}, WhereCondOr)
+}
+
 func (whs Wheres) WhereCombineIn(fields []string, values [][]interface{}) Wheres {
 	fieldValues := make(map[string][]interface{}, len(fields))
 	for i, field := range fields {
@@ -338,12 +702,79 @@ func (whs Wheres) WhereCombineIn(fields []string, values [][]interface{}) Wheres
 }
 
 func (whs Wheres) OrWhere(field string, operation Operation, value interface{}) Wheres {
-	return whs.where(field, operation, []interface{}{value}, WhereCondOr)
+	return whs.where(field, operation, _whereValues(operation, value), WhereCondOr)
+}
+
+func (whs Wheres) WhereBetween(field string, from, to interface{}) Wheres {
+	return whs.where(field, OpBetween, []interface{}{from, to}, WhereCondAnd)
+}
+
+func (whs Wheres) OrWhereBetween(field string, from, to interface{}) Wheres {
+	return whs.where(field, OpBetween, []interface{}{from, to}, WhereCondOr)
+}
+
+func (whs Wheres) WhereNotBetween(field string, from, to interface{}) Wheres {
+	return whs.where(field, OpNotBetween, []interface{}{from, to}, WhereCondAnd)
+}
+
+// WhereLike matches field against pattern, whose wildcards are kept, use
+// EscapeLike on user input or WhereContains and the like.
+func (whs Wheres) WhereLike(field string, pattern string) Wheres {
+	return whs.where(field, OpLike, []interface{}{pattern}, WhereCondAnd)
+}
+
+func (whs Wheres) OrWhereLike(field string, pattern string) Wheres {
+	return whs.where(field, OpLike, []interface{}{pattern}, WhereCondOr)
+}
+
+func (whs Wheres) WhereNotLike(field string, pattern string) Wheres {
+	return whs.where(field, OpNotLike, []interface{}{pattern}, WhereCondAnd)
+}
+
+// WhereContains matches field containing s, wildcards in s are escaped.
+func (whs Wheres) WhereContains(field string, s string) Wheres {
+	return whs.WhereLike(field, "%"+EscapeLike(s)+"%")
+}
+
+func (whs Wheres) WhereStartsWith(field string, s string) Wheres {
+	return whs.WhereLike(field, EscapeLike(s)+"%")
+}
+
+func (whs Wheres) WhereEndsWith(field string, s string) Wheres {
+	return whs.WhereLike(field, "%"+EscapeLike(s))
+}
+
+// On compares the column field with the column other.
+func (whs Wheres) On(field string, operation Operation, other string) Wheres {
+	return whs.where(field, operation, []interface{}{Column(other)}, WhereCondAnd)
+}
+
+func (whs Wheres) OrOn(field string, operation Operation, other string) Wheres {
+	return whs.where(field, operation, []interface{}{Column(other)}, WhereCondOr)
+}
+
+// WhereRaw adds the condition sql verbatim, binding its ? placeholders to
+// args. sql must never carry user input.
+func (whs Wheres) WhereRaw(sql string, args ...interface{}) Wheres {
+	return append(whs, &Where{Expr: &Expr{SQL: sql, Args: args}, Cond: WhereCondAnd})
+}
+
+func (whs Wheres) OrWhereRaw(sql string, args ...interface{}) Wheres {
+	return append(whs, &Where{Expr: &Expr{SQL: sql, Args: args}, Cond: WhereCondOr})
 }
 
 type GetWhereFn func(w Wheres) Wheres
 
 func (whs Wheres) where(field string, operation Operation, value []interface{}, cond WhereCond) Wheres {
+	// comparing with NULL is never true, so = nil means IS NULL
+	if len(value) == 1 && _isNull(value[0]) {
+		switch operation {
+		case OpEq:
+			operation, value = OpIsNull, nil
+		case "!=", "<>":
+			operation, value = OpIsNotNull, nil
+		}
+	}
 	wh := &Where{
 		Field:     field,
 		Operation: operation,
@@ -354,17 +785,20 @@ func (whs Wheres) where(field string, operation Operation, value []interface{},
 	return whs
 }
 
-func (whs Wheres) string(needBracket bool, getPh func(typeKind reflect.Kind) string) (string, []interface{}) {
+func (whs Wheres) string(needBracket bool, st *stmt) (string, []interface{}, error) {
 	if len(whs) == 0 {
-		return "", make([]interface{}, 0)
+		return "", make([]interface{}, 0), nil
 	}
 	if len(whs) == 1 {
-		return whs[0].string(true, false, getPh)
+		return whs[0].string(true, false, st)
 	}
 	resList := make([]string, 0, len(whs))
 	whArgs := make([]interface{}, 0)
 	for i := 0; i < len(whs); i++ {
-		str, args := whs[i].string(i == 0, len(whs) > 1, getPh)
+		str, args, err := whs[i].string(i == 0, len(whs) > 1, st)
+		if err != nil {
+			return "", nil, err
+		}
 		resList = append(resList, str)
 		whArgs = append(whArgs, args...)
 		if whs[i].CombineRight == nil {
@@ -376,25 +810,47 @@ func (whs Wheres) string(needBracket bool, getPh func(typeKind reflect.Kind) str
 	}
 	res := strings.Join(resList, " ")
 	if needBracket {
-		return _wrapBracket(res), whArgs
+		return _wrapBracket(res), whArgs, nil
 	}
-	return res, whArgs
+	return res, whArgs, nil
 }
 
 type NewBuilderOpt struct {
 	Parameterized bool
 	Reuse         bool
+	// Dialect decides how placeholders and identifiers are rendered,
+	// MySQL is used when it is nil.
+	Dialect Dialect
+	// AllowUnknownOperators renders operators missing from the registry
+	// verbatim instead of failing the build. Never set it when operators
+	// come from user input.
+	AllowUnknownOperators bool
+	// Immutable makes every method return a new builder and leave the one
+	// it is called on unchanged, so a base query can be shared between
+	// goroutines and extended by each of them.
+	Immutable bool
 }
 
 func New(table string, opts ...NewBuilderOpt) *SQLBulder {
 	reuse := true
 	parameterized := true
+	allowUnknownOps := false
+	immutable := false
+	var dialect Dialect = MySQL
 	if len(opts) > 0 {
 		reuse = opts[0].Reuse
 		parameterized = opts[0].Parameterized
+		allowUnknownOps = opts[0].AllowUnknownOperators
+		immutable = opts[0].Immutable
+		if opts[0].Dialect != nil {
+			dialect = opts[0].Dialect
+		}
 	}
 	b := newSQLBuilder(table, reuse)
 	b.parameterized = parameterized
+	b.dialect = dialect
+	b.allowUnknownOps = allowUnknownOps
+	b.immutable = immutable
 	return b
 }
 
@@ -406,6 +862,8 @@ func newSQLBuilder(table string, reuse bool) *SQLBulder {
 		builder = new(SQLBulder)._default()
 	}
 	builder.tableName = table
+	builder.reuse = reuse
+	builder.released = false
 	return builder
 }
 
@@ -418,24 +876,36 @@ func putBackBuilder(builder *SQLBulder) {
 func (builder *SQLBulder) _default() *SQLBulder {
 	builder.offsetSize = -1
 	builder.limitSize = -1
-	builder.delimiter = "`"
 	builder.insert = new(Insert)
 	builder.tableName = ""
 	builder.action = ""
-	builder.fields = make([]string, 0)
+	builder.distinct = false
+	builder.fields = make([]interface{}, 0)
 	builder.args = make([]interface{}, 0)
 	builder.whereList = make(Wheres, 0)
+	builder.havingList = make(Wheres, 0)
 	builder.orders = make(Orders, 0)
 	builder.groups = make(Groups, 0)
 	builder.parameterized = false
-	builder.delimiter = "`"
+	builder.dialect = MySQL
+	builder.allowUnknownOps = false
 	builder.values = make(Values, 0)
+	builder.columns = nil
 	builder.forceIndexName = ""
+	builder.fromSub = nil
+	builder.lock = ""
+	builder.compounds = make([]*Compound, 0)
+	builder.ctes = make([]*CTE, 0)
+	builder.windows = make(namedWindows, 0)
+	builder.joins = make(Joins, 0)
 	builder.reuse = true
+	builder.immutable = false
+	builder.errs = nil
 	return builder
 }
 
 func (builder *SQLBulder) Wheres(fn GetWhereFn) *SQLBulder {
+	builder = builder.derive()
 	wh := &Where{
 		Cond: WhereCondAnd,
 	}
@@ -444,6 +914,7 @@ func (builder *SQLBulder) Wheres(fn GetWhereFn) *SQLBulder {
 }
 
 func (builder *SQLBulder) OrWheres(fn GetWhereFn) *SQLBulder {
+	builder = builder.derive()
 	wh := &Where{
 		Cond: WhereCondOr,
 	}
@@ -452,16 +923,87 @@ func (builder *SQLBulder) OrWheres(fn GetWhereFn) *SQLBulder {
 }
 
 func (builder *SQLBulder) Where(field string, operation Operation, value interface{}) *SQLBulder {
+	builder = builder.derive()
 	builder.whereList = builder.whereList.Where(field, operation.lower(), value)
 	return builder
 }
 
 func (builder *SQLBulder) OrWhere(field string, operation Operation, value interface{}) *SQLBulder {
+	builder = builder.derive()
 	builder.whereList = builder.whereList.OrWhere(field, operation.lower(), value)
 	return builder
 }
 
+// WhereRaw adds the condition sql verbatim, binding its ? placeholders to
+// args, e.g. WhereRaw("a + b > ?", 10). sql must never carry user input.
+func (builder *SQLBulder) WhereRaw(sql string, args ...interface{}) *SQLBulder {
+	builder = builder.derive()
+	builder.whereList = builder.whereList.WhereRaw(sql, args...)
+	return builder
+}
+
+func (builder *SQLBulder) OrWhereRaw(sql string, args ...interface{}) *SQLBulder {
+	builder = builder.derive()
+	builder.whereList = builder.whereList.OrWhereRaw(sql, args...)
+	return builder
+}
+
+func (builder *SQLBulder) WhereBetween(field string, from, to interface{}) *SQLBulder {
+	builder = builder.derive()
+	builder.whereList = builder.whereList.WhereBetween(field, from, to)
+	return builder
+}
+
+func (builder *SQLBulder) OrWhereBetween(field string, from, to interface{}) *SQLBulder {
+	builder = builder.derive()
+	builder.whereList = builder.whereList.OrWhereBetween(field, from, to)
+	return builder
+}
+
+func (builder *SQLBulder) WhereNotBetween(field string, from, to interface{}) *SQLBulder {
+	builder = builder.derive()
+	builder.whereList = builder.whereList.WhereNotBetween(field, from, to)
+	return builder
+}
+
+func (builder *SQLBulder) WhereLike(field string, pattern string) *SQLBulder {
+	builder = builder.derive()
+	builder.whereList = builder.whereList.WhereLike(field, pattern)
+	return builder
+}
+
+func (builder *SQLBulder) OrWhereLike(field string, pattern string) *SQLBulder {
+	builder = builder.derive()
+	builder.whereList = builder.whereList.OrWhereLike(field, pattern)
+	return builder
+}
+
+func (builder *SQLBulder) WhereNotLike(field string, pattern string) *SQLBulder {
+	builder = builder.derive()
+	builder.whereList = builder.whereList.WhereNotLike(field, pattern)
+	return builder
+}
+
+func (builder *SQLBulder) WhereContains(field string, s string) *SQLBulder {
+	builder = builder.derive()
+	builder.whereList = builder.whereList.WhereContains(field, s)
+	return builder
+}
+
+func (builder *SQLBulder) WhereStartsWith(field string, s string) *SQLBulder {
+	builder = builder.derive()
+	builder.whereList = builder.whereList.WhereStartsWith(field, s)
+	return builder
+}
+
+func (builder *SQLBulder) WhereEndsWith(field string, s string) *SQLBulder {
+	builder = builder.derive()
+	builder.whereList = builder.whereList.WhereEndsWith(field, s)
+	return builder
+}
+
 func (builder *SQLBulder) WhereIn(field string, value ...interface{}) *SQLBulder {
+	builder = builder.derive()
 	if len(value) == 0 {
 		return builder
 	}
@@ -474,6 +1016,7 @@ func (builder *SQLBulder) WhereIn(field string, value ...interface{}) *SQLBulder
 }
 
 func (builder *SQLBulder) OrWhereIn(field string, value ...interface{}) *SQLBulder {
+	builder = builder.derive()
 	if len(value) == 0 {
 		return builder
 	}
@@ -486,6 +1029,7 @@ func (builder *SQLBulder) OrWhereIn(field string, value ...interface{}) *SQLBuld
 }
 
 func (builder *SQLBulder) WhereNotIn(field string, value ...interface{}) *SQLBulder {
+	builder = builder.derive()
 	if len(value) == 0 {
 		return builder
 	}
@@ -498,6 +1042,7 @@ func (builder *SQLBulder) WhereNotIn(field string, value ...interface{}) *SQLBul
 }
 
 func (builder *SQLBulder) OrWhereNotIn(field string, value ...interface{}) *SQLBulder {
+	builder = builder.derive()
 	if len(value) == 0 {
 		return builder
 	}
@@ -510,6 +1055,7 @@ func (builder *SQLBulder) OrWhereNotIn(field string, value ...interface{}) *SQLB
 }
 
 func (builder *SQLBulder) WhereCombineIn(field []string, value [][]interface{}) *SQLBulder {
+	builder = builder.derive()
 	if len(value) == 0 {
 		return builder
 	}
@@ -517,6 +1063,54 @@ func (builder *SQLBulder) WhereCombineIn(field []string, value [][]interface{})
 	return builder
 }
 
+func (builder *SQLBulder) WhereNull(field string) *SQLBulder {
+	builder = builder.derive()
+	builder.whereList = builder.whereList.WhereNull(field)
+	return builder
+}
+
+func (builder *SQLBulder) OrWhereNull(field string) *SQLBulder {
+	builder = builder.derive()
+	builder.whereList = builder.whereList.OrWhereNull(field)
+	return builder
+}
+
+func (builder *SQLBulder) WhereNotNull(field string) *SQLBulder {
+	builder = builder.derive()
+	builder.whereList = builder.whereList.WhereNotNull(field)
+	return builder
+}
+
+func (builder *SQLBulder) OrWhereNotNull(field string) *SQLBulder {
+	builder = builder.derive()
+	builder.whereList = builder.whereList.OrWhereNotNull(field)
+	return builder
+}
+
+func (builder *SQLBulder) WhereExists(sub *SQLBulder) *SQLBulder {
+	builder = builder.derive()
+	builder.whereList = builder.whereList.WhereExists(sub)
+	return builder
+}
+
+func (builder *SQLBulder) OrWhereExists(sub *SQLBulder) *SQLBulder {
+	builder = builder.derive()
+	builder.whereList = builder.whereList.OrWhereExists(sub)
+	return builder
+}
+
+func (builder *SQLBulder) WhereNotExists(sub *SQLBulder) *SQLBulder {
+	builder = builder.derive()
+	builder.whereList = builder.whereList.WhereNotExists(sub)
+	return builder
+}
+
+func (builder *SQLBulder) OrWhereNotExists(sub *SQLBulder) *SQLBulder {
+	builder = builder.derive()
+	builder.whereList = builder.whereList.OrWhereNotExists(sub)
+	return builder
+}
+
 func (builder *SQLBulder) GetWheresByField(field string) []*Where {
 	return builder.whereList.findByField(field)
 }
@@ -531,80 +1125,453 @@ func (builder *SQLBulder) GetValuesByField(field string) []interface{} {
 	return res
 }
 
-func (builder *SQLBulder) Select(fields ...string) *SQLBulder {
-	builder.action = SQLActionSelect
-	builder.fields = append(builder.fields, fields...)
+// With defines the common table expression name for the query.
+func (builder *SQLBulder) With(name string, query *SQLBulder) *SQLBulder {
+	builder = builder.derive()
+	builder.ctes = append(builder.ctes, &CTE{
+		Name:  name,
+		Query: query,
+	})
 	return builder
 }
 
-func (builder *SQLBulder) OrderBy(field string, order OrderEnum) *SQLBulder {
-	builder.orders = append(builder.orders, &Order{
-		Field: field,
-		Order: order,
+// WithRecursive defines the recursive common table expression name, made of
+// anchor UNION ALL recursive.
+func (builder *SQLBulder) WithRecursive(name string, columns []string, anchor, recursive *SQLBulder) *SQLBulder {
+	builder = builder.derive()
+	builder.ctes = append(builder.ctes, &CTE{
+		Name:      name,
+		Columns:   columns,
+		Query:     anchor,
+		Recursive: recursive,
 	})
 	return builder
 }
 
-func (builder *SQLBulder) OrderBys(fields []string, order OrderEnum) *SQLBulder {
+// FromSub selects from the derived table sub, named alias in the outer query.
+func (builder *SQLBulder) FromSub(sub *SQLBulder, alias string) *SQLBulder {
+	builder = builder.derive()
+	builder.fromSub = sub
+	builder.tableName = alias
+	return builder
+}
+
+func (builder *SQLBulder) Select(fields ...string) *SQLBulder {
+	builder = builder.derive()
+	builder.action = SQLActionSelect
 	for _, field := range fields {
-		builder.orders = append(builder.orders, &Order{
-			Field: field,
-			Order: order,
-		})
+		builder.fields = append(builder.fields, field)
 	}
 	return builder
 }
 
-func (builder *SQLBulder) GroupBy(fields ...string) *SQLBulder {
-	builder.groups = append(builder.groups, fields...)
+// SelectExpr selects the expressions with their args.
+func (builder *SQLBulder) SelectExpr(exprs ...Expr) *SQLBulder {
+	builder = builder.derive()
+	builder.action = SQLActionSelect
+	for _, expr := range exprs {
+		builder.fields = append(builder.fields, expr)
+	}
 	return builder
 }
 
-func (builder *SQLBulder) Limit(limit int64) *SQLBulder {
-	builder.limitSize = Limit(limit)
+// Distinct removes the duplicated rows from the result.
+func (builder *SQLBulder) Distinct() *SQLBulder {
+	builder = builder.derive()
+	builder.action = SQLActionSelect
+	builder.distinct = true
 	return builder
 }
 
-func (builder *SQLBulder) Offset(offset int64) *SQLBulder {
-	builder.offsetSize = Offset(offset)
+// SelectRaw selects the expressions verbatim, they must never carry user
+// input.
+func (builder *SQLBulder) SelectRaw(exprs ...string) *SQLBulder {
+	builder = builder.derive()
+	builder.action = SQLActionSelect
+	for _, expr := range exprs {
+		builder.fields = append(builder.fields, Raw(expr))
+	}
 	return builder
 }
 
-func (builder *SQLBulder) Update(values map[string]interface{}) *SQLBulder {
-	builder.action = SQLActionUpdate
-	builder.values = []map[string]interface{}{values}
+// SelectOver selects the window function fn, e.g. "ROW_NUMBER()" or
+// "LAG(amount)", computed over the window over. fn is validated like the
+// function form of a column; use SelectOverRaw for anything else.
+func (builder *SQLBulder) SelectOver(fn string, over *Window, alias string) *SQLBulder {
+	builder = builder.derive()
+	builder.action = SQLActionSelect
+	builder.fields = append(builder.fields, &WindowFunc{
+		Func:  fn,
+		Over:  over,
+		Alias: alias,
+	})
 	return builder
 }
 
-func (builder *SQLBulder) ForceIndex(index string) *SQLBulder {
-	builder.forceIndexName = ForceIndex(index)
+// SelectOverRaw is SelectOver with a window function written as raw SQL,
+// e.g. "LAG(amount, 1, 0)". fn is written verbatim, so it must never hold
+// user input.
+func (builder *SQLBulder) SelectOverRaw(fn Raw, over *Window, alias string) *SQLBulder {
+	builder = builder.derive()
+	builder.action = SQLActionSelect
+	builder.fields = append(builder.fields, &WindowFunc{
+		Func:  string(fn),
+		Over:  over,
+		Alias: alias,
+		raw:   true,
+	})
 	return builder
 }
 
-func (builder *SQLBulder) OnDuplicateUpdateKeys(keys ...string) *SQLBulder {
-	builder.insert.onDupKeyUpdates = keys
+// Window defines the named window name in the WINDOW clause.
+func (builder *SQLBulder) Window(name string, w *Window) *SQLBulder {
+	builder = builder.derive()
+	if w == nil {
+		return builder.setErr(_wrapError(ErrInvalidExpression, "window "+name+" has no definition"))
+	}
+	builder.windows = append(builder.windows, &namedWindow{
+		name:   name,
+		window: w,
+	})
 	return builder
 }
 
-func (builder *SQLBulder) Insert(values map[string]interface{}) *SQLBulder {
-	builder.action = SQLActionInsert
-	builder.values = []map[string]interface{}{values}
+func (builder *SQLBulder) OrderBy(field string, order OrderEnum) *SQLBulder {
+	builder = builder.derive()
+	builder.orders = append(builder.orders, &Order{
+		Field: field,
+		Order: order,
+	})
 	return builder
 }
 
-func (builder *SQLBulder) InsertIgnore(values map[string]interface{}) *SQLBulder {
-	builder.Insert(values)
-	builder.insert.ignore = true
+func (builder *SQLBulder) OrderBys(fields []string, order OrderEnum) *SQLBulder {
+	builder = builder.derive()
+	for _, field := range fields {
+		builder.orders = append(builder.orders, &Order{
+			Field: field,
+			Order: order,
+		})
+	}
 	return builder
 }
 
-func (builder *SQLBulder) BatchInsert(values []map[string]interface{}) *SQLBulder {
-	builder.action = SQLActionInsert
-	builder.values = values
+// OrderByExpr sorts by the expression with its args.
+func (builder *SQLBulder) OrderByExpr(expr Expr, order OrderEnum) *SQLBulder {
+	builder = builder.derive()
+	builder.orders = append(builder.orders, &Order{
+		Expr:  &expr,
+		Order: order,
+	})
+	return builder
+}
+
+// OrderByRaw sorts by the expression verbatim, including its direction. It
+// must never carry user input.
+func (builder *SQLBulder) OrderByRaw(expr string) *SQLBulder {
+	builder = builder.derive()
+	builder.orders = append(builder.orders, &Order{
+		Field: expr,
+		Raw:   true,
+	})
+	return builder
+}
+
+func (builder *SQLBulder) Join(table string, on GetWhereFn) *SQLBulder {
+	return builder.join(JoinInner, table, on)
+}
+
+func (builder *SQLBulder) LeftJoin(table string, on GetWhereFn) *SQLBulder {
+	return builder.join(JoinLeft, table, on)
+}
+
+func (builder *SQLBulder) RightJoin(table string, on GetWhereFn) *SQLBulder {
+	return builder.join(JoinRight, table, on)
+}
+
+func (builder *SQLBulder) CrossJoin(table string) *SQLBulder {
+	return builder.join(JoinCross, table, nil)
+}
+
+func (builder *SQLBulder) join(typ JoinType, table string, on GetWhereFn) *SQLBulder {
+	builder = builder.derive()
+	j := &Join{
+		Type:  typ,
+		Table: table,
+	}
+	if on != nil {
+		j.On = on(j.On)
+	}
+	builder.joins = append(builder.joins, j)
+	return builder
+}
+
+func (builder *SQLBulder) GroupBy(fields ...string) *SQLBulder {
+	builder = builder.derive()
+	for _, field := range fields {
+		builder.groups = append(builder.groups, field)
+	}
+	return builder
+}
+
+// GroupByRaw groups by the expressions verbatim, they must never carry user
+// input.
+func (builder *SQLBulder) GroupByRaw(exprs ...string) *SQLBulder {
+	builder = builder.derive()
+	for _, expr := range exprs {
+		builder.groups = append(builder.groups, Raw(expr))
+	}
+	return builder
+}
+
+func (builder *SQLBulder) Havings(fn GetWhereFn) *SQLBulder {
+	builder = builder.derive()
+	wh := &Where{
+		Cond: WhereCondAnd,
+	}
+	builder.havingList = append(builder.havingList, wh.Wheres(fn))
+	return builder
+}
+
+func (builder *SQLBulder) OrHavings(fn GetWhereFn) *SQLBulder {
+	builder = builder.derive()
+	wh := &Where{
+		Cond: WhereCondOr,
+	}
+	builder.havingList = append(builder.havingList, wh.Wheres(fn))
+	return builder
+}
+
+func (builder *SQLBulder) Having(field string, operation Operation, value interface{}) *SQLBulder {
+	builder = builder.derive()
+	builder.havingList = builder.havingList.Where(field, operation.lower(), value)
+	return builder
+}
+
+func (builder *SQLBulder) OrHaving(field string, operation Operation, value interface{}) *SQLBulder {
+	builder = builder.derive()
+	builder.havingList = builder.havingList.OrWhere(field, operation.lower(), value)
+	return builder
+}
+
+// HavingRaw adds the condition sql verbatim to HAVING, binding its ?
+// placeholders to args. sql must never carry user input.
+func (builder *SQLBulder) HavingRaw(sql string, args ...interface{}) *SQLBulder {
+	builder = builder.derive()
+	builder.havingList = builder.havingList.WhereRaw(sql, args...)
+	return builder
+}
+
+func (builder *SQLBulder) OrHavingRaw(sql string, args ...interface{}) *SQLBulder {
+	builder = builder.derive()
+	builder.havingList = builder.havingList.OrWhereRaw(sql, args...)
+	return builder
+}
+
+func (builder *SQLBulder) Limit(limit int64) *SQLBulder {
+	builder = builder.derive()
+	builder.limitSize = Limit(limit)
+	return builder
+}
+
+func (builder *SQLBulder) Offset(offset int64) *SQLBulder {
+	builder = builder.derive()
+	builder.offsetSize = Offset(offset)
+	return builder
+}
+
+// Union combines the result with other. Once a select has compounds, its
+// OrderBy, Limit and Offset apply to the combined result.
+func (builder *SQLBulder) Union(other *SQLBulder) *SQLBulder {
+	return builder.compound(CompoundUnion, other)
+}
+
+func (builder *SQLBulder) UnionAll(other *SQLBulder) *SQLBulder {
+	return builder.compound(CompoundUnionAll, other)
+}
+
+func (builder *SQLBulder) Intersect(other *SQLBulder) *SQLBulder {
+	return builder.compound(CompoundIntersect, other)
+}
+
+func (builder *SQLBulder) Except(other *SQLBulder) *SQLBulder {
+	return builder.compound(CompoundExcept, other)
+}
+
+func (builder *SQLBulder) compound(op CompoundOp, other *SQLBulder) *SQLBulder {
+	builder = builder.derive()
+	builder.compounds = append(builder.compounds, &Compound{
+		Op:    op,
+		Query: other,
+	})
+	return builder
+}
+
+func (builder *SQLBulder) ForUpdate() *SQLBulder {
+	builder = builder.derive()
+	builder.lock = LockForUpdate
+	return builder
+}
+
+func (builder *SQLBulder) ForShare() *SQLBulder {
+	builder = builder.derive()
+	builder.lock = LockForShare
+	return builder
+}
+
+// Update sets the columns in values, in addition to the columns already set
+// by Update, Increment or Decrement.
+func (builder *SQLBulder) Update(values map[string]interface{}) *SQLBulder {
+	return builder.updateFields(values)
+}
+
+// Increment adds n to the column field, in addition to the columns already
+// set by Update.
+func (builder *SQLBulder) Increment(field string, n interface{}) *SQLBulder {
+	return builder.updateFields(map[string]interface{}{field: Col(field).Add(n)})
+}
+
+// Decrement subtracts n from the column field, in addition to the columns
+// already set by Update.
+func (builder *SQLBulder) Decrement(field string, n interface{}) *SQLBulder {
+	return builder.updateFields(map[string]interface{}{field: Col(field).Sub(n)})
+}
+
+// updateFields sets the columns in values, keeping the other columns of an
+// update. A column set twice keeps its last value.
+func (builder *SQLBulder) updateFields(values map[string]interface{}) *SQLBulder {
+	builder = builder.derive()
+	merged := make(map[string]interface{}, len(values))
+	if builder.action == SQLActionUpdate && len(builder.values) > 0 {
+		for f, v := range builder.values[0] {
+			merged[f] = v
+		}
+	}
+	for f, v := range values {
+		merged[f] = v
+	}
+	builder.action = SQLActionUpdate
+	builder.values = []map[string]interface{}{merged}
+	return builder
+}
+
+// Columns fixes the order of the columns written by Insert, BatchInsert and
+// Update, columns not listed follow in alphabetical order.
+func (builder *SQLBulder) Columns(columns ...string) *SQLBulder {
+	builder = builder.derive()
+	builder.columns = columns
+	return builder
+}
+
+func (builder *SQLBulder) ForceIndex(index string) *SQLBulder {
+	builder = builder.derive()
+	builder.forceIndexName = ForceIndex(index)
+	return builder
+}
+
+func (builder *SQLBulder) OnDuplicateUpdateKeys(keys ...string) *SQLBulder {
+	builder = builder.derive()
+	builder.insert.onDupKeyUpdates = keys
+	return builder
+}
+
+// OnConflict sets the conflict target of an upsert, the columns of a unique
+// index, followed by DoNothing or DoUpdate. Mysql checks every unique key.
+func (builder *SQLBulder) OnConflict(columns ...string) *SQLBulder {
+	builder = builder.derive()
+	builder.insert.conflict.columns = columns
+	return builder
+}
+
+// OnConflictConstraint sets the conflict target of an upsert by the name of
+// a constraint, postgres only.
+func (builder *SQLBulder) OnConflictConstraint(name string) *SQLBulder {
+	builder = builder.derive()
+	builder.insert.conflict.constraint = name
+	return builder
+}
+
+// DoNothing skips the rows which conflict.
+func (builder *SQLBulder) DoNothing() *SQLBulder {
+	builder = builder.derive()
+	builder.insert.conflict.doNothing = true
+	return builder
+}
+
+// DoUpdate overwrites the columns of the rows which conflict with the
+// inserted values.
+func (builder *SQLBulder) DoUpdate(columns ...string) *SQLBulder {
+	builder = builder.OnDuplicateUpdateKeys(columns...)
+	builder.insert.conflict.doNothing = false
+	return builder
+}
+
+// DoUpdateSet assigns values to the columns of the rows which conflict, a
+// value can be an Expr referring to the inserted values with Excluded.
+func (builder *SQLBulder) DoUpdateSet(values map[string]interface{}) *SQLBulder {
+	builder = builder.derive()
+	set := make(map[string]interface{}, len(builder.insert.conflict.set)+len(values))
+	for col, value := range builder.insert.conflict.set {
+		set[col] = value
+	}
+	for col, value := range values {
+		set[col] = value
+	}
+	builder.insert.conflict.set = set
+	builder.insert.conflict.doNothing = false
+	return builder
+}
+
+// DoUpdateWhere only updates the rows which conflict and match the
+// conditions, postgres and sqlite only.
+func (builder *SQLBulder) DoUpdateWhere(fn GetWhereFn) *SQLBulder {
+	builder = builder.derive()
+	where := builder.insert.conflict.where
+	builder.insert.conflict.where = fn(where[:len(where):len(where)])
+	return builder
+}
+
+func (builder *SQLBulder) Insert(values map[string]interface{}) *SQLBulder {
+	builder = builder.derive()
+	builder.action = SQLActionInsert
+	builder.values = []map[string]interface{}{values}
+	return builder
+}
+
+func (builder *SQLBulder) InsertIgnore(values map[string]interface{}) *SQLBulder {
+	builder = builder.Insert(values)
+	builder.insert.ignore = true
+	return builder
+}
+
+func (builder *SQLBulder) BatchInsert(values []map[string]interface{}) *SQLBulder {
+	builder = builder.derive()
+	builder.action = SQLActionInsert
+	builder.values = values
+	return builder
+}
+
+// Chunk splits a batch insert within limits, see BuildAll.
+func (builder *SQLBulder) Chunk(limits ChunkLimits) *SQLBulder {
+	builder = builder.derive()
+	builder.insert.chunk = limits
+	return builder
+}
+
+// Batch sets how a batch insert handles rows with different columns.
+func (builder *SQLBulder) Batch(mode BatchMode) *SQLBulder {
+	builder = builder.derive()
+	switch mode {
+	case BatchStrict, BatchFillDefault, BatchFillNull, BatchGroup:
+	default:
+		return builder.setErr(_wrapError(ErrInvalidExpression, fmt.Sprintf("unknown batch mode %q", string(mode))))
+	}
+	builder.insert.batchMode = mode
 	return builder
 }
 
 func (builder *SQLBulder) BatchInsertIgnore(values []map[string]interface{}) *SQLBulder {
+	builder = builder.derive()
 	builder.action = SQLActionInsert
 	builder.values = values
 	builder.insert.ignore = true
@@ -612,11 +1579,13 @@ func (builder *SQLBulder) BatchInsertIgnore(values []map[string]interface{}) *SQ
 }
 
 func (builder *SQLBulder) Delete() *SQLBulder {
+	builder = builder.derive()
 	builder.action = SQLActionDelete
 	return builder
 }
 
 func (builder *SQLBulder) Query() *SQLBulder {
+	builder = builder.derive()
 	builder.action = SQLActionSelect
 	return builder
 }
@@ -639,148 +1608,892 @@ func (builder *SQLBulder) IsSingleAggregationMin() bool {
 
 func (builder *SQLBulder) isSingleAggregationFun(funName string) bool {
 	exp, _ := regexp.Compile(`(?i)` + funName + `\((.*?)\)`)
-	return len(builder.fields) == 1 && exp.MatchString(builder.fields[0])
+	if len(builder.fields) != 1 {
+		return false
+	}
+	field, ok := builder.fields[0].(string)
+	return ok && exp.MatchString(field)
 }
 
 func (builder *SQLBulder) Build() (string, []interface{}, error) {
 	return builder.build()
 }
 
+// BuildAll builds the statements needed to run the builder, which are
+// several for a batch insert grouped with BatchGroup or split by Chunk, and
+// one otherwise.
+func (builder *SQLBulder) BuildAll() ([]Statement, error) {
+	if err := builder.checkReleased(); err != nil {
+		return nil, err
+	}
+	return builder.statements()
+}
+
+func (builder *SQLBulder) statements() ([]Statement, error) {
+	if builder.action != SQLActionInsert || (builder.insert.batchMode != BatchGroup && builder.insert.chunk == (ChunkLimits{})) {
+		sql, args, err := builder.render(builder.newStmt())
+		if err != nil {
+			return nil, err
+		}
+		return []Statement{{SQL: sql, Args: args}}, nil
+	}
+	if len(builder.errs) > 0 {
+		return nil, builder.errs[0]
+	}
+	if builder.tableName == "" {
+		return nil, ErrEmptyTable
+	}
+	groups, err := builder.insertGroups()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Statement, 0, len(groups))
+	for _, rows := range groups {
+		sql, args, err := builder.buildInsertRows(builder.newStmt(), rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, Statement{SQL: sql, Args: args})
+	}
+	return res, nil
+}
+
 func (builder *SQLBulder) BuildWithTable(name string) (string, []interface{}, error) {
+	builder = builder.derive()
 	builder.tableName = name
 	return builder.build()
 }
 
 func (builder *SQLBulder) build() (string, []interface{}, error) {
-	defer func() {
-		putBackBuilder(builder)
-	}()
+	if err := builder.checkReleased(); err != nil {
+		return "", nil, err
+	}
+	return builder.render(builder.newStmt())
+}
+
+// render builds the statement without releasing the builder, stopping at
+// the first problem.
+func (builder *SQLBulder) render(st *stmt) (string, []interface{}, error) {
+	if len(builder.errs) > 0 {
+		return "", nil, builder.errs[0]
+	}
 	if builder.tableName == "" {
-		return "", nil, _sqlError("empty table name")
+		return "", nil, ErrEmptyTable
 	}
 	switch builder.action {
 	case SQLActionInsert:
-		return builder.buildInsert()
+		return builder.buildInsert(st)
 	case SQLActionUpdate:
-		return builder.buildUpdate()
+		return builder.buildUpdate(st)
 	case SQLActionSelect:
-		return builder.buildSelect()
+		return builder.buildSelect(st)
 	case SQLActionDelete:
-		return builder.buildDelete()
+		return builder.buildDelete(st)
+	}
+	return "", nil, ErrUnknownAction
+}
+
+// setErr records an error met while the builder is set up, Build reports
+// the first one and Validate all of them.
+func (builder *SQLBulder) setErr(err error) *SQLBulder {
+	builder.errs = append(builder.errs, err)
+	return builder
+}
+
+// stmt holds the state shared by every part of a statement while it is
+// rendered, so numbered placeholders stay in step with the args slice.
+type stmt struct {
+	dialect         Dialect
+	parameterized   bool
+	allowUnknownOps bool
+	n               int
+	// upsert is set while the assignments and the condition of an upsert
+	// are rendered, the only place Excluded is valid
+	upsert bool
+}
+
+func (builder *SQLBulder) newStmt() *stmt {
+	return &stmt{
+		dialect:         builder.dialect,
+		parameterized:   builder.parameterized,
+		allowUnknownOps: builder.allowUnknownOps,
+	}
+}
+
+// subquery renders sub as a bracketed select continuing the placeholders of
+// the outer statement.
+func (st *stmt) subquery(sub *SQLBulder) (string, []interface{}, error) {
+	sql, args, err := st.nested(sub)
+	if err != nil {
+		return "", nil, err
+	}
+	return _wrapBracket(sql), args, nil
+}
+
+// nested renders sub, a subquery, a cte or a compound query, as a select
+// continuing the placeholders of the outer statement. sub is checked like
+// Build checks a builder, so that its problems are not lost.
+func (st *stmt) nested(sub *SQLBulder) (string, []interface{}, error) {
+	if err := sub.checkReleased(); err != nil {
+		return "", nil, err
+	}
+	if len(sub.errs) > 0 {
+		return "", nil, sub.errs[0]
 	}
-	return "", nil, _sqlError("wrong action")
+	if sub.action != SQLActionSelect {
+		return "", nil, _wrapError(ErrUnknownAction, "a nested query must be a select, not "+string(sub.action))
+	}
+	return sub.buildSelect(st)
 }
 
-func (builder *SQLBulder) placeholder(typeKind reflect.Kind) string {
-	if builder.parameterized {
-		return "?"
+// operands renders every value as a column or a placeholder, returning the
+// args bound by the placeholders.
+func (st *stmt) operands(values []interface{}) ([]string, []interface{}, error) {
+	operands := make([]string, 0, len(values))
+	args := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		switch value := v.(type) {
+		case Column:
+			name, err := quoteName(st.dialect, string(value))
+			if err != nil {
+				return nil, nil, err
+			}
+			operands = append(operands, name)
+			continue
+		case Expr:
+			sql, exprArgs, err := st.bind(value)
+			if err != nil {
+				return nil, nil, err
+			}
+			operands = append(operands, sql)
+			args = append(args, exprArgs...)
+			continue
+		case Excluded:
+			if !st.upsert {
+				return nil, nil, _wrapError(ErrInvalidExpression, "Excluded is only valid in DoUpdateSet and DoUpdateWhere")
+			}
+			ref, ok := st.dialect.(ExcludedDialect)
+			if !ok {
+				return nil, nil, _wrapError(ErrUnsupported, st.dialect.Name()+" can not refer to the inserted values")
+			}
+			name, err := quoteIdent(st.dialect, string(value))
+			if err != nil {
+				return nil, nil, err
+			}
+			operands = append(operands, ref.ExcludedColumn(name))
+			continue
+		}
+		ph, arg := st.value(v)
+		operands = append(operands, ph)
+		args = append(args, arg)
+	}
+	return operands, args, nil
+}
+
+func (st *stmt) placeholder(typeKind reflect.Kind) string {
+	if st.parameterized {
+		st.n++
+		return st.dialect.Placeholder(st.n)
 	} else {
 		if typeKind == reflect.String {
-			return `"%s"`
+			if _features(st.dialect).BackslashEscapes {
+				return `"%s"`
+			}
+			// double quotes mark an identifier in standard sql
+			return `'%s'`
 		}
 		return "%v"
 	}
 }
 
-func (builder *SQLBulder) buildInsert() (string, []interface{}, error) {
+// value renders the placeholder of the plain value v and returns its arg.
+// This is the only place a string is escaped, for the literal of a non
+// parameterized builder.
+func (st *stmt) value(v interface{}) (string, interface{}) {
+	kind := _kindOf(v)
+	ph := st.placeholder(kind)
+	if !st.parameterized && kind == reflect.String {
+		v = st.escape(reflect.ValueOf(v).String())
+	}
+	return ph, v
+}
+
+// values renders the comma separated placeholders of plain values.
+func (st *stmt) values(values []interface{}) (string, []interface{}) {
+	phs := make([]string, 0, len(values))
+	args := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		ph, arg := st.value(v)
+		phs = append(phs, ph)
+		args = append(args, arg)
+	}
+	return strings.Join(phs, ","), args
+}
+
+// escape escapes s for the string literal of a non parameterized builder,
+// standard sql only doubles the single quotes.
+func (st *stmt) escape(s string) string {
+	if _features(st.dialect).BackslashEscapes {
+		return _escape(s)
+	}
+	return strings.ReplaceAll(s, "'", "''")
+}
 
-	if len(builder.values) == 0 {
-		return "", nil, _sqlError("wrong insert values")
+func (builder *SQLBulder) buildInsert(st *stmt) (string, []interface{}, error) {
+	groups, err := builder.insertGroups()
+	if err != nil {
+		return "", nil, err
+	}
+	if len(groups) > 1 {
+		return "", nil, ErrMultipleStatements
 	}
+	return builder.buildInsertRows(st, groups[0])
+}
 
-	fields := make([]string, 0, len(builder.values))
+// insertGroups splits the rows to insert into the rows inserted by each
+// statement, following the batch mode and the chunk limits.
+func (builder *SQLBulder) insertGroups() ([][]map[string]interface{}, error) {
+	if !builder.hasValues() {
+		return nil, ErrNoValues
+	}
+	groups := [][]map[string]interface{}{builder.values}
+	switch builder.insert.batchMode {
+	case BatchStrict:
+		if err := _checkBatchColumns(builder.values); err != nil {
+			return nil, err
+		}
+	case BatchGroup:
+		groups = _groupByColumns(builder.values)
+	}
+	if builder.insert.chunk == (ChunkLimits{}) {
+		return groups, nil
+	}
+	chunks := make([][]map[string]interface{}, 0, len(groups))
+	for _, rows := range groups {
+		chunks = append(chunks, builder.chunkRows(rows)...)
+	}
+	return chunks, nil
+}
+
+// chunkRows splits rows within the chunk limits, estimating the size of the
+// statement from its columns and args.
+func (builder *SQLBulder) chunkRows(rows []map[string]interface{}) [][]map[string]interface{} {
+	limits := builder.insert.chunk
+	columns := _orderedColumns(_unionColumns(rows), nil)
+	header := len("INSERT INTO  () VALUES ") + len(builder.tableName)
+	for _, col := range columns {
+		header += len(col) + 3
+	}
+	for _, key := range builder.insert.onDupKeyUpdates {
+		header += 2*len(key) + 20
+	}
+	// the assignments and the condition of an upsert are bound in every
+	// statement, a condition which does not render fails the build later
+	set := builder.insert.conflict.set
+	setPlaceholders, setSize := _rowCost(set, _orderedColumns(set, nil))
+	header += setSize
+	st := builder.newStmt()
+	st.upsert = true
+	where, whereArgs, _ := builder.insert.conflict.where.string(false, st)
+	setPlaceholders += len(whereArgs)
+	header += len(where) + 8*len(whereArgs)
+
+	chunks := make([][]map[string]interface{}, 0, 1)
+	start, placeholders, size := 0, setPlaceholders, header
+	for i, row := range rows {
+		rowPlaceholders, rowSize := _rowCost(row, columns)
+		full := i > start && ((limits.MaxRows > 0 && i-start >= limits.MaxRows) ||
+			(limits.MaxPlaceholders > 0 && placeholders+rowPlaceholders > limits.MaxPlaceholders) ||
+			(limits.MaxBytes > 0 && size+rowSize > limits.MaxBytes))
+		if full {
+			chunks = append(chunks, rows[start:i:i])
+			start, placeholders, size = i, setPlaceholders, header
+		}
+		placeholders += rowPlaceholders
+		size += rowSize
+	}
+	return append(chunks, rows[start:])
+}
+
+// _rowCost estimates the placeholders and bytes a row adds to an insert.
+func _rowCost(row map[string]interface{}, columns []string) (int, int) {
+	placeholders, size := 0, 3
+	for _, col := range columns {
+		v, ok := row[col]
+		if !ok || _isNull(v) {
+			size += len("DEFAULT,")
+			continue
+		}
+		args := []interface{}{v}
+		if e, ok := v.(Expr); ok {
+			size += len(e.SQL)
+			args = e.Args
+		}
+		for _, arg := range args {
+			placeholders++
+			size += len("$1,")
+			switch a := arg.(type) {
+			case string:
+				size += len(a)
+			case []byte:
+				size += len(a)
+			default:
+				size += 8
+			}
+		}
+	}
+	return placeholders, size
+}
+
+func (builder *SQLBulder) buildInsertRows(st *stmt, values []map[string]interface{}) (string, []interface{}, error) {
+	table, err := quoteTable(st.dialect, builder.tableName)
+	if err != nil {
+		return "", nil, err
+	}
+	// the value of the columns a row is missing, the mode itself is never
+	// written into the statement
+	fill := ""
+	switch builder.insert.batchMode {
+	case BatchFillDefault:
+		if _features(st.dialect).NoDefaultValues {
+			return "", nil, _wrapError(ErrUnsupported, st.dialect.Name()+" does not support DEFAULT values in a batch insert")
+		}
+		fill = "DEFAULT"
+	case BatchFillNull:
+		fill = "NULL"
+	}
+	fields := make([]string, 0, len(values[0]))
 	fieldsHolderSb := new(strings.Builder)
-	placeholders := make([]string, 0, len(builder.values))
-	needEscapeFields := map[string]bool{}
-	for field, val := range builder.values[0] {
+	for _, field := range _orderedColumns(_unionColumns(values), builder.columns) {
+		quoted, err := quoteName(st.dialect, field)
+		if err != nil {
+			return "", nil, err
+		}
 		fields = append(fields, field)
-		fieldsHolderSb.WriteString(_wrapField(field, builder.delimiter))
+		fieldsHolderSb.WriteString(quoted)
 		fieldsHolderSb.WriteString(",")
-		typeKind := reflect.TypeOf(val).Kind()
-		placeholders = append(placeholders, builder.placeholder(typeKind))
-		needEscapeFields[field] = !builder.parameterized && typeKind == reflect.String
 	}
 	fieldsHolder := fieldsHolderSb.String()
 	fieldsHolder = _wrapBracket(fieldsHolder[:len(fieldsHolder)-1])
-	batchHolder := _wrapBracket(strings.Join(placeholders, ","))
-	values := builder.values
 	args := make([]interface{}, 0, len(values)*len(fields))
+	rows := make([]string, 0, len(values))
 	for i := range values {
+		placeholders := make([]string, 0, len(fields))
 		for _, field := range fields {
-			arg := values[i][field]
-			if needEscapeFields[field] {
-				arg = _escape(arg.(string))
+			arg, ok := values[i][field]
+			if !ok {
+				placeholders = append(placeholders, fill)
+				continue
+			}
+			if _isNull(arg) {
+				placeholders = append(placeholders, "NULL")
+				continue
 			}
+			if e, ok := arg.(Expr); ok {
+				sql, exprArgs, err := st.bind(e)
+				if err != nil {
+					return "", nil, err
+				}
+				placeholders = append(placeholders, sql)
+				args = append(args, exprArgs...)
+				continue
+			}
+			ph, arg := st.value(arg)
+			placeholders = append(placeholders, ph)
 			args = append(args, arg)
 		}
+		rows = append(rows, _wrapBracket(strings.Join(placeholders, ",")))
 	}
-	ignore := ""
+	ignore, ignoreClause := "", ""
 	if builder.insert.ignore {
-		ignore = "IGNORE"
+		ignore, ignoreClause, err = st.dialect.InsertIgnore()
+		if err != nil {
+			return "", nil, err
+		}
 	}
+	upsert, upsertArgs, err := builder.upsert(st, fields)
+	if err != nil {
+		return "", nil, err
+	}
+	// an ignore written as a conflict clause, such as the ON CONFLICT DO
+	// NOTHING of postgres, can not be followed by another one. mysql ignores
+	// the other errors of an upsert with INSERT IGNORE.
+	if ignoreClause != "" && upsert != "" {
+		return "", nil, _wrapError(ErrInvalidUpsert, "an insert ignore can not be an upsert as well")
+	}
+	args = append(args, upsertArgs...)
 	return _joinString([]string{
-		"INSERT", ignore, "INTO", builder.tableName, fieldsHolder,
+		"INSERT", ignore, "INTO", table, fieldsHolder,
 		"VALUES",
-		_stringRepeatJoin(batchHolder, ",", len(values)),
-		builder.insert.onDupKeyUpdates.String(),
+		strings.Join(rows, ","),
+		ignoreClause,
+		upsert,
 	}, " "), args, nil
 }
 
-func (builder *SQLBulder) buildDelete() (string, []interface{}, error) {
-	wheres, args := builder.whereList.string(false, builder.placeholder)
+// upsert renders the clause handling the rows which conflict, empty unless
+// the insert is an upsert. fields are the columns the statement inserts.
+func (builder *SQLBulder) upsert(st *stmt, fields []string) (string, []interface{}, error) {
+	conflict := builder.insert.conflict
+	keys := builder.insert.onDupKeyUpdates
+	if len(keys) == 0 && len(conflict.set) == 0 && !conflict.doNothing {
+		if len(conflict.columns) > 0 || conflict.constraint != "" {
+			return "", nil, _wrapError(ErrInvalidUpsert, "a conflict target without DoNothing or columns to update")
+		}
+		return "", nil, nil
+	}
+	if builder.insert.batchMode == BatchGroup {
+		// a group only overwrites the columns it inserts, the others would
+		// be overwritten with their default
+		keys = _keepColumns(keys, fields)
+		if len(keys) == 0 && len(conflict.set) == 0 && !conflict.doNothing {
+			return "", nil, _wrapError(ErrInconsistentBatchColumns, "a group of rows inserts none of the columns to update")
+		}
+	}
+	if _, err := quoteIdents(st.dialect, append(keys[:len(keys):len(keys)], conflict.columns...)); err != nil {
+		return "", nil, err
+	}
+	if conflict.constraint != "" {
+		if _, err := quoteIdent(st.dialect, conflict.constraint); err != nil {
+			return "", nil, err
+		}
+	}
+	c := &ConflictClause{Columns: conflict.columns, Constraint: conflict.constraint, DoNothing: conflict.doNothing}
+	args := make([]interface{}, 0, len(conflict.set))
+	if !conflict.doNothing {
+		st.upsert = true
+		defer func() { st.upsert = false }()
+		for _, key := range keys {
+			// an assignment of DoUpdateSet wins over the inserted value
+			if _, ok := conflict.set[key]; !ok {
+				c.Update = append(c.Update, key)
+			}
+		}
+		for _, col := range _orderedColumns(conflict.set, nil) {
+			column, err := quoteIdent(st.dialect, col)
+			if err != nil {
+				return "", nil, err
+			}
+			value := conflict.set[col]
+			if _isNull(value) {
+				c.Set = append(c.Set, column+" = NULL")
+				continue
+			}
+			operands, valueArgs, err := st.operands([]interface{}{value})
+			if err != nil {
+				return "", nil, err
+			}
+			c.Set = append(c.Set, column+" = "+operands[0])
+			args = append(args, valueArgs...)
+		}
+		where, whereArgs, err := conflict.where.string(false, st)
+		if err != nil {
+			return "", nil, err
+		}
+		c.Where = where
+		args = append(args, whereArgs...)
+	}
+	sql, err := st.dialect.Upsert(c)
+	if err != nil {
+		return "", nil, err
+	}
+	return sql, args, nil
+}
+
+func (builder *SQLBulder) buildDelete(st *stmt) (string, []interface{}, error) {
+	if err := builder.checkWriteJoins(st); err != nil {
+		return "", nil, err
+	}
+	table, err := quoteTable(st.dialect, builder.tableName)
+	if err != nil {
+		return "", nil, err
+	}
+	joins, args, err := builder.joins.string(st)
+	if err != nil {
+		return "", nil, err
+	}
+	wheres, whereArgs, err := builder.whereList.string(false, st)
+	if err != nil {
+		return "", nil, err
+	}
 	if wheres == "" {
-		return "", nil, _sqlError("can not delete without where conditions")
+		return "", nil, ErrNoWhere
+	}
+	target := ""
+	if len(builder.joins) > 0 {
+		if target, err = quoteName(st.dialect, _tableAlias(builder.tableName)); err != nil {
+			return "", nil, err
+		}
 	}
-	return _joinString([]string{"DELETE FROM", builder.tableName, _getWheres(wheres)}, " "), args, nil
+	args = append(args, whereArgs...)
+	return _joinString([]string{"DELETE", target, "FROM", table, joins, _getWheres(wheres)}, " "), args, nil
 }
 
-func (builder *SQLBulder) buildUpdate() (string, []interface{}, error) {
-	if len(builder.values) == 0 {
-		return "", nil, _sqlError("wrong update values")
+// checkWriteJoins reports joins in an UPDATE or DELETE, which are only
+// rendered with the multiple-table syntax of mysql.
+func (builder *SQLBulder) checkWriteJoins(st *stmt) error {
+	if len(builder.joins) > 0 && !_features(st.dialect).WriteJoins {
+		return _wrapError(ErrUnsupported, st.dialect.Name()+" does not support joins in "+string(builder.action))
+	}
+	return nil
+}
+
+func (builder *SQLBulder) buildUpdate(st *stmt) (string, []interface{}, error) {
+	if !builder.hasValues() {
+		return "", nil, ErrNoValues
+	}
+	if err := builder.checkWriteJoins(st); err != nil {
+		return "", nil, err
+	}
+	table, err := quoteTable(st.dialect, builder.tableName)
+	if err != nil {
+		return "", nil, err
+	}
+	joins, updateArgs, err := builder.joins.string(st)
+	if err != nil {
+		return "", nil, err
 	}
 	updatePartSb := new(strings.Builder)
-	for field, val := range builder.values[0] {
-		valtyp := reflect.TypeOf(val)
-		if valtyp.String() == "mydb.UpdateRaw" {
-			updatePartSb.WriteString(_wrapField(field, builder.delimiter))
+	for _, field := range _orderedColumns(builder.values[0], builder.columns) {
+		val := builder.values[0][field]
+		column, err := quoteName(st.dialect, field)
+		if err != nil {
+			return "", nil, err
+		}
+		if _isNull(val) {
+			updatePartSb.WriteString(column)
+			updatePartSb.WriteString(" = NULL,")
+			continue
+		}
+		// a parameterized raw update binds its args like an Expr, so its
+		// placeholders are numbered with the others
+		if raw, ok := val.(UpdateRaw); ok && st.parameterized {
+			val = NewExpr(raw.Expr, raw.Args...)
+		}
+		switch raw := val.(type) {
+		case UpdateRaw:
+			updatePartSb.WriteString(column)
 			updatePartSb.WriteString(" = ")
-			updatePartSb.WriteString(val.(UpdateRaw).Expr)
+			updatePartSb.WriteString(raw.Expr)
 			updatePartSb.WriteString(",")
-			builder.args = append(builder.args, val.(UpdateRaw).Args...)
-		} else {
-			typeKind := valtyp.Kind()
-			updatePartSb.WriteString(_wrapField(field, builder.delimiter))
+			updateArgs = append(updateArgs, raw.Args...)
+		case Expr:
+			sql, exprArgs, err := st.bind(raw)
+			if err != nil {
+				return "", nil, err
+			}
+			updatePartSb.WriteString(column)
 			updatePartSb.WriteString(" = ")
-			updatePartSb.WriteString(builder.placeholder(typeKind))
+			updatePartSb.WriteString(sql)
 			updatePartSb.WriteString(",")
-			if !builder.parameterized && typeKind == reflect.String {
-				val = _escape(val.(string))
-			}
-			builder.args = append(builder.args, val)
+			updateArgs = append(updateArgs, exprArgs...)
+		default:
+			ph, arg := st.value(val)
+			updatePartSb.WriteString(column)
+			updatePartSb.WriteString(" = ")
+			updatePartSb.WriteString(ph)
+			updatePartSb.WriteString(",")
+			updateArgs = append(updateArgs, arg)
 		}
 	}
-	wheres, args := builder.whereList.string(false, builder.placeholder)
+	wheres, args, err := builder.whereList.string(false, st)
+	if err != nil {
+		return "", nil, err
+	}
 	if wheres == "" {
-		return "", nil, _sqlError("can not update without where conditions")
+		return "", nil, ErrNoWhere
 	}
 	updatePart := updatePartSb.String()
-	builder.args = append(builder.args, args...)
+	updateArgs = append(updateArgs, args...)
 	return _joinString([]string{
-		"UPDATE", builder.tableName, "SET", updatePart[:len(updatePart)-1],
-		_getWheres(wheres)}, " "), builder.args, nil
+		"UPDATE", table, joins, "SET", updatePart[:len(updatePart)-1],
+		_getWheres(wheres)}, " "), updateArgs, nil
+}
+
+// clause renders one clause of a statement, an empty string means the
+// clause is left out.
+type clause func(st *stmt) (string, []interface{}, error)
+
+func (builder *SQLBulder) buildSelect(st *stmt) (string, []interface{}, error) {
+	// clauses are rendered in the order sql requires them, which keeps the
+	// placeholders numbered in the same order as the args.
+	clauses := []clause{
+		builder.selectWith,
+		builder.selectFields,
+		builder.selectFrom,
+		builder.selectJoins,
+		builder.selectWheres,
+		builder.selectGroups,
+		builder.selectHavings,
+		builder.selectWindows,
+	}
+	if len(builder.compounds) > 0 {
+		clauses = append(clauses, builder.selectCompounds)
+	}
+	clauses = append(clauses,
+		builder.selectOrders,
+		builder.selectLimit,
+		builder.selectLock,
+	)
+	return _buildClauses(st, clauses)
+}
+
+func (builder *SQLBulder) selectCompounds(st *stmt) (string, []interface{}, error) {
+	resList := make([]string, 0, len(builder.compounds))
+	args := make([]interface{}, 0)
+	for _, c := range builder.compounds {
+		sql, queryArgs, err := st.nested(c.Query)
+		if err != nil {
+			return "", nil, err
+		}
+		// a query with its own ordering or limit has to be bracketed to
+		// keep it from applying to the whole compound
+		q := c.Query
+		if len(q.orders) > 0 || q.limitSize != -1 || q.offsetSize != -1 || len(q.compounds) > 0 {
+			sql = _wrapBracket(sql)
+		}
+		resList = append(resList, string(c.Op)+" "+sql)
+		args = append(args, queryArgs...)
+	}
+	return strings.Join(resList, " "), args, nil
 }
 
-func (builder *SQLBulder) buildSelect() (string, []interface{}, error) {
-	wheres, args := builder.whereList.string(false, builder.placeholder)
-	return _joinString([]string{
-		"SELECT", strings.Join(builder.fields, ","), "FROM", builder.tableName,
-		builder.forceIndexName.String(),
-		_getWheres(wheres),
-		builder.orders.String(),
-		builder.groups.String(),
-		builder.limitSize.String(),
-		builder.offsetSize.String(),
-	}, " "), args, nil
+func (builder *SQLBulder) selectWith(st *stmt) (string, []interface{}, error) {
+	if len(builder.ctes) == 0 {
+		return "", nil, nil
+	}
+	recursive := false
+	resList := make([]string, 0, len(builder.ctes))
+	args := make([]interface{}, 0)
+	for _, cte := range builder.ctes {
+		sql, cteArgs, err := cte.string(st)
+		if err != nil {
+			return "", nil, err
+		}
+		recursive = recursive || cte.Recursive != nil
+		resList = append(resList, sql)
+		args = append(args, cteArgs...)
+	}
+	with := "WITH"
+	if recursive && !_features(st.dialect).ImplicitRecursiveWith {
+		with = "WITH RECURSIVE"
+	}
+	return with + " " + strings.Join(resList, ", "), args, nil
+}
+
+func (builder *SQLBulder) selectFields(st *stmt) (string, []interface{}, error) {
+	fields := make([]string, 0, len(builder.fields))
+	args := make([]interface{}, 0)
+	for _, field := range builder.fields {
+		str, fieldArgs, err := st.field(field)
+		if err != nil {
+			return "", nil, err
+		}
+		fields = append(fields, str)
+		args = append(args, fieldArgs...)
+	}
+	selectKeyword := "SELECT"
+	if builder.distinct {
+		selectKeyword = "SELECT DISTINCT"
+	}
+	return _joinString([]string{selectKeyword, strings.Join(fields, ",")}, " "), args, nil
+}
+
+// field renders one column of the select list.
+func (st *stmt) field(field interface{}) (string, []interface{}, error) {
+	switch f := field.(type) {
+	case string:
+		column, err := quoteColumn(st.dialect, f, true)
+		return column, nil, err
+	case Raw:
+		return string(f), nil, nil
+	case Expr:
+		return st.bind(f)
+	case *WindowFunc:
+		str, err := f.string(st)
+		return str, nil, err
+	}
+	return "", nil, _wrapError(ErrInvalidExpression, fmt.Sprintf("unsupported type %T", field))
+}
+
+func (builder *SQLBulder) selectFrom(st *stmt) (string, []interface{}, error) {
+	var table string
+	var err error
+	args := make([]interface{}, 0)
+	if builder.fromSub != nil {
+		sub, subArgs, err := st.subquery(builder.fromSub)
+		if err != nil {
+			return "", nil, err
+		}
+		alias, err := quoteIdent(st.dialect, builder.tableName)
+		if err != nil {
+			return "", nil, err
+		}
+		table = sub + " " + alias
+		args = subArgs
+	} else if table, err = quoteTable(st.dialect, builder.tableName); err != nil {
+		return "", nil, err
+	}
+	index := builder.forceIndexName
+	if index != "" {
+		quoted, err := quoteIdent(st.dialect, string(index))
+		if err != nil {
+			return "", nil, err
+		}
+		index = ForceIndex(quoted)
+	}
+	return _joinString([]string{"FROM", table, st.dialect.IndexHint(index)}, " "), args, nil
+}
+
+func (builder *SQLBulder) selectJoins(st *stmt) (string, []interface{}, error) {
+	return builder.joins.string(st)
+}
+
+func (builder *SQLBulder) selectWheres(st *stmt) (string, []interface{}, error) {
+	wheres, args, err := builder.whereList.string(false, st)
+	return _getWheres(wheres), args, err
+}
+
+func (builder *SQLBulder) selectGroups(st *stmt) (string, []interface{}, error) {
+	groups, err := builder.groups.string(st)
+	return groups, nil, err
+}
+
+func (builder *SQLBulder) selectHavings(st *stmt) (string, []interface{}, error) {
+	havings, args, err := builder.havingList.string(false, st)
+	return _getHavings(havings), args, err
+}
+
+func (builder *SQLBulder) selectWindows(st *stmt) (string, []interface{}, error) {
+	windows, err := builder.windows.string(st)
+	return windows, nil, err
+}
+
+func (builder *SQLBulder) selectOrders(st *stmt) (string, []interface{}, error) {
+	if len(builder.orders) == 0 && _features(st.dialect).LimitNeedsOrder &&
+		(builder.limitSize != -1 || builder.offsetSize != -1) {
+		// any order will do, the rows are limited in no particular order
+		return "ORDER BY (SELECT NULL)", nil, nil
+	}
+	return builder.orders.string(st)
+}
+
+func (builder *SQLBulder) selectLimit(st *stmt) (string, []interface{}, error) {
+	return st.dialect.LimitOffset(builder.limitSize, builder.offsetSize), nil, nil
+}
+
+func (builder *SQLBulder) selectLock(st *stmt) (string, []interface{}, error) {
+	if builder.lock == "" {
+		return "", nil, nil
+	}
+	lock, err := st.dialect.Lock(builder.lock)
+	return lock, nil, err
+}
+
+func _buildClauses(st *stmt, clauses []clause) (string, []interface{}, error) {
+	parts := make([]string, 0, len(clauses))
+	args := make([]interface{}, 0)
+	for _, c := range clauses {
+		str, clauseArgs, err := c(st)
+		if err != nil {
+			return "", nil, err
+		}
+		if str != "" {
+			parts = append(parts, str)
+		}
+		args = append(args, clauseArgs...)
+	}
+	return strings.Join(parts, " "), args, nil
+}
+
+// _orderedColumns returns the keys of row, those listed in order first and
+// the rest sorted, so the same values always render the same sql.
+func _orderedColumns(row map[string]interface{}, order []string) []string {
+	columns := make([]string, 0, len(row))
+	listed := make(map[string]bool, len(order))
+	for _, col := range order {
+		if _, ok := row[col]; ok && !listed[col] {
+			columns = append(columns, col)
+			listed[col] = true
+		}
+	}
+	rest := make([]string, 0, len(row)-len(columns))
+	for col := range row {
+		if !listed[col] {
+			rest = append(rest, col)
+		}
+	}
+	sort.Strings(rest)
+	return append(columns, rest...)
+}
+
+// hasValues reports whether there is a column to write.
+func (builder *SQLBulder) hasValues() bool {
+	return len(builder.values) > 0 && len(builder.values[0]) > 0
+}
+
+// _keepColumns returns the columns which are among fields.
+func _keepColumns(columns, fields []string) []string {
+	kept := make([]string, 0, len(columns))
+	for _, col := range columns {
+		for _, field := range fields {
+			if col == field {
+				kept = append(kept, col)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+// _unionColumns returns every column of the rows.
+func _unionColumns(rows []map[string]interface{}) map[string]interface{} {
+	if len(rows) == 1 {
+		return rows[0]
+	}
+	columns := make(map[string]interface{}, len(rows[0]))
+	for _, row := range rows {
+		for col := range row {
+			columns[col] = nil
+		}
+	}
+	return columns
+}
+
+// _groupByColumns groups the rows with the same columns, the groups keep
+// the order in which their first row appears.
+func _groupByColumns(rows []map[string]interface{}) [][]map[string]interface{} {
+	groups := make([][]map[string]interface{}, 0, 1)
+	index := make(map[string]int)
+	for _, row := range rows {
+		key := strings.Join(_orderedColumns(row, nil), ",")
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], row)
+	}
+	return groups
+}
+
+// _checkBatchColumns reports rows that do not have the columns of the
+// first row.
+func _checkBatchColumns(rows []map[string]interface{}) error {
+	for i := 1; i < len(rows); i++ {
+		same := len(rows[i]) == len(rows[0])
+		for col := range rows[0] {
+			if _, ok := rows[i][col]; !ok {
+				same = false
+				break
+			}
+		}
+		if !same {
+			return _wrapError(ErrInconsistentBatchColumns, fmt.Sprintf("row %d", i))
+		}
+	}
+	return nil
 }
 
 func _getWheres(wheres string) string {
@@ -791,16 +2504,40 @@ func _getWheres(wheres string) string {
 	return "WHERE " + wheres
 }
 
-func _wrapField(field string, del string) string {
-	return del + field + del
+func _getHavings(havings string) string {
+	if havings == "" {
+		return ""
+	}
+
+	return "HAVING " + havings
+}
+
+// _tableAlias returns the alias of a table written as "table alias" or
+// "table AS alias", or the table itself when it has none.
+func _tableAlias(table string) string {
+	parts := strings.Fields(table)
+	return parts[len(parts)-1]
 }
 
 func _wrapBracket(field string) string {
 	return "(" + field + ")"
 }
 
-func _sqlError(msg string) error {
-	return fmt.Errorf("invalid sql: %s", msg)
+// _isNull reports whether v is nil or a nil pointer, both are rendered as
+// NULL.
+func _isNull(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+func _kindOf(v interface{}) reflect.Kind {
+	if v == nil {
+		return reflect.Invalid
+	}
+	return reflect.TypeOf(v).Kind()
 }
 
 func _escape(sql string) string {
@@ -989,10 +2726,3 @@ func _joinString(elems []string, sep string) string {
 	}
 	return b.String()
 }
-
-func _stringRepeatJoin(str, sep string, n int) string {
-	if str == "" || n <= 1 {
-		return str
-	}
-	return str + strings.Repeat(sep+str, n-1)
-}
@@ -1,12 +1,17 @@
 package builder
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 )
 
 func TestSQLBuild(t *testing.T) {
-	sql, args, _ := New("test").
+	// the expected sql below is rendered in non-parameterized mode
+	raw := NewBuilderOpt{Reuse: true}
+
+	sql, args, _ := New("test", raw).
 		Select("a", "b", "c", "max(d)").
 		ForceIndex("_uk_a_b_c").
 		Where("a", "=", 11).
@@ -32,22 +37,22 @@ func TestSQLBuild(t *testing.T) {
 		BuildWithTable("test_p1")
 
 	fmt.Println("select", sql, args)
-	if sql != `SELECT a,b,c,max(d) FROM test_p1 FORCE INDEX(_uk_a_b_c) WHERE a = %v AND (d = %v AND e >= "%s" OR f <= %v) OR c = %v OR (d = %v AND e = "%s") AND g in (%v,%v,%v,%v,%v) AND h in ("%s") AND (aa,bb) in ((%v,%v),(%v,%v)) ORDER BY a ASC,b DESC GROUP BY c LIMIT 10 OFFSET 20` {
+	if sql != "SELECT `a`,`b`,`c`,max(`d`) FROM `test_p1` FORCE INDEX(`_uk_a_b_c`) WHERE `a` = %v AND (`d` = %v AND `e` >= \"%s\" OR `f` <= %v) OR `c` = %v OR (`d` = %v AND `e` = \"%s\") AND `g` in (%v,%v,%v,%v,%v) AND `h` in (\"%s\") AND (`aa`,`bb`) in ((%v,%v),(%v,%v)) GROUP BY `c` ORDER BY `a` ASC,`b` DESC LIMIT 10 OFFSET 20" {
 		t.Error("[select] wrong sql result")
 	}
 
-	sql, args, _ = New("test").Insert(map[string]interface{}{
+	sql, args, _ = New("test", raw).Insert(map[string]interface{}{
 		"a": 1,
 		"b": 2,
 	}).OnDuplicateUpdateKeys("a", "b").Build()
 
 	fmt.Println("insert", sql, args)
 	
-	if sql != "INSERT INTO test (`a`,`b`) VALUES (%v,%v) ON DUPLICATE KEY UPDATE a = VALUES(a), b = VALUES(b)" {
+	if sql != "INSERT INTO `test` (`a`,`b`) VALUES (%v,%v) ON DUPLICATE KEY UPDATE `a` = VALUES(`a`), `b` = VALUES(`b`)" {
 		t.Error("[insert] wrong sql result")
 	}
 
-	sql, args, _ = New("test").BatchInsert([]map[string]interface{}{
+	sql, args, _ = New("test", raw).BatchInsert([]map[string]interface{}{
 		{
 			"a": 1,
 			"b": "jack",
@@ -61,7 +66,7 @@ func TestSQLBuild(t *testing.T) {
 
 	fmt.Println("batch insert", sql, args)
 
-	sql, args, _ = New("test").Where("a", "=", 11).Update(map[string]interface{}{
+	sql, args, _ = New("test", raw).Where("a", "=", 11).Update(map[string]interface{}{
 		"c": 1,
 		"b": 2,
 		"d": "jack",
@@ -73,15 +78,858 @@ func TestSQLBuild(t *testing.T) {
 
 	fmt.Println("update", sql, args)
 
-	if sql != "UPDATE test SET `c` = %v,`b` = %v,`d` = \"%s\",`f` = %d | f WHERE a = %v" {
+	if sql != "UPDATE `test` SET `b` = %v,`c` = %v,`d` = \"%s\",`f` = %d | f WHERE `a` = %v" {
 		t.Error("[update] wrong sql result")
 	}
 
-	sql, args, _ = New("test").Where("a", "=", 11).Delete().Build()
+	sql, args, _ = New("test", raw).Where("a", "=", 11).Delete().Build()
 
 	fmt.Println("delete", sql, args)
 
-	if sql != "DELETE FROM test WHERE a = %v" {
+	if sql != "DELETE FROM `test` WHERE `a` = %v" {
 		t.Error("[delete] wrong sql result")
 	}
-}
\ No newline at end of file
+}
+
+func TestSQLBuildPostgres(t *testing.T) {
+	pg := NewBuilderOpt{Parameterized: true, Reuse: true, Dialect: PostgreSQL}
+
+	sql, args, _ := New("test", pg).
+		Select("a", "b").
+		Where("a", "=", 11).
+		Wheres(func(w Wheres) Wheres {
+			return w.Where("d", "=", 12).OrWhere("f", "<=", "what?")
+		}).
+		WhereIn("g", []int{1, 2, 3}).
+		WhereCombineIn([]string{"aa", "bb"}, [][]interface{}{
+			{10, 20},
+			{11, 22},
+		}).
+		Limit(10).
+		Query().
+		Build()
+
+	if sql != `SELECT "a","b" FROM "test" WHERE "a" = $1 AND ("d" = $2 OR "f" <= $3) AND "g" in ($4,$5,$6) AND ("aa","bb") in (($7,$8),($9,$10)) LIMIT 10` {
+		t.Error("[select] wrong sql result", sql)
+	}
+	if fmt.Sprint(args) != "[11 12 what? 1 2 3 10 20 11 22]" {
+		t.Error("[select] wrong args", args)
+	}
+
+	sql, args, _ = New("test", pg).BatchInsert([]map[string]interface{}{
+		{"a": 1},
+		{"a": 2},
+	}).Build()
+
+	if sql != `INSERT INTO "test" ("a") VALUES ($1),($2)` {
+		t.Error("[insert] wrong sql result", sql)
+	}
+	if fmt.Sprint(args) != "[1 2]" {
+		t.Error("[insert] wrong args", args)
+	}
+
+	sql, args, _ = New("test", pg).Where("a", "=", 11).Update(map[string]interface{}{
+		"c": 1,
+	}).Build()
+
+	if sql != `UPDATE "test" SET "c" = $1 WHERE "a" = $2` {
+		t.Error("[update] wrong sql result", sql)
+	}
+	if fmt.Sprint(args) != "[1 11]" {
+		t.Error("[update] wrong args", args)
+	}
+
+	sql, args, _ = New("test", NewBuilderOpt{Dialect: PostgreSQL}).Insert(map[string]interface{}{"s": "o'x"}).Build()
+
+	if sql != `INSERT INTO "test" ("s") VALUES ('%s')` || fmt.Sprint(args) != "[o''x]" {
+		t.Error("[literal] wrong sql result", sql, args)
+	}
+
+	sql, args, _ = New("test", NewBuilderOpt{Dialect: PostgreSQL}).Select("a").
+		Where("s", "=", "o'x").WhereIn("t", "a'", "b").Having("n", ">", "c'").GroupBy("a").Query().Build()
+	if sql != `SELECT "a" FROM "test" WHERE "s" = '%s' AND "t" in ('%s','%s') GROUP BY "a" HAVING "n" > '%s'` ||
+		fmt.Sprint(args) != "[o''x a'' b c'']" {
+		t.Error("[literal where] wrong sql result", sql, args)
+	}
+}
+
+func TestSQLBuildJoin(t *testing.T) {
+	opt := NewBuilderOpt{Parameterized: true, Reuse: true}
+
+	sql, args, _ := New("users u", opt).
+		Select("u.id", "o.amount").
+		Join("orders o", func(w Wheres) Wheres {
+			return w.On("o.user_id", "=", "u.id").Where("o.status", "=", 1)
+		}).
+		LeftJoin("profiles p", func(w Wheres) Wheres {
+			return w.On("p.user_id", "=", "u.id")
+		}).
+		CrossJoin("regions r").
+		Where("u.age", ">", 18).
+		Query().
+		Build()
+
+	if sql != "SELECT `u`.`id`,`o`.`amount` FROM `users` `u` INNER JOIN `orders` `o` ON `o`.`user_id` = `u`.`id` AND `o`.`status` = ? LEFT JOIN `profiles` `p` ON `p`.`user_id` = `u`.`id` CROSS JOIN `regions` `r` WHERE `u`.`age` > ?" {
+		t.Error("[select] wrong sql result", sql)
+	}
+	if fmt.Sprint(args) != "[1 18]" {
+		t.Error("[select] wrong args", args)
+	}
+
+	sql, args, _ = New("users u", opt).
+		Join("orders o", func(w Wheres) Wheres {
+			return w.On("o.user_id", "=", "u.id").Where("o.status", "=", 2)
+		}).
+		Where("u.id", "=", 3).
+		Update(map[string]interface{}{"level": 5}).
+		Build()
+
+	if sql != "UPDATE `users` `u` INNER JOIN `orders` `o` ON `o`.`user_id` = `u`.`id` AND `o`.`status` = ? SET `level` = ? WHERE `u`.`id` = ?" {
+		t.Error("[update] wrong sql result", sql)
+	}
+	if fmt.Sprint(args) != "[2 5 3]" {
+		t.Error("[update] wrong args", args)
+	}
+
+	sql, args, _ = New("users AS u", opt).
+		Join("orders o", func(w Wheres) Wheres {
+			return w.On("o.user_id", "=", "u.id")
+		}).
+		Where("o.status", "=", 4).
+		Delete().
+		Build()
+
+	if sql != "DELETE `u` FROM `users` AS `u` INNER JOIN `orders` `o` ON `o`.`user_id` = `u`.`id` WHERE `o`.`status` = ?" {
+		t.Error("[delete] wrong sql result", sql)
+	}
+	if fmt.Sprint(args) != "[4]" {
+		t.Error("[delete] wrong args", args)
+	}
+
+	_, _, err := New("users u", NewBuilderOpt{Parameterized: true, Dialect: PostgreSQL}).
+		Join("orders o", func(w Wheres) Wheres {
+			return w.On("o.user_id", "=", "u.id")
+		}).
+		Where("o.status", "=", 4).
+		Delete().
+		Build()
+	if err == nil {
+		t.Error("[delete] expected an error for joins on postgres")
+	}
+}
+
+func TestSQLBuildSubquery(t *testing.T) {
+	pg := NewBuilderOpt{Parameterized: true, Reuse: true, Dialect: PostgreSQL}
+
+	sql, args, _ := New("users", pg).
+		Select("id").
+		Where("status", "=", 1).
+		WhereIn("id", New("orders").Select("user_id").Where("amount", ">", 100).Query()).
+		Where("score", ">", New("scores").Select("avg(score)").Where("year", "=", 2023).Query()).
+		WhereExists(New("bans").Select("1").Where("bans.user_id", "=", Col("users.id")).Query()).
+		Wheres(func(w Wheres) Wheres {
+			return w.WhereNotExists(New("logs").Select("1").Where("level", "=", "error").Query())
+		}).
+		Query().
+		Build()
+
+	if sql != `SELECT "id" FROM "users" WHERE "status" = $1 AND "id" in (SELECT "user_id" FROM "orders" WHERE "amount" > $2) AND "score" > (SELECT avg("score") FROM "scores" WHERE "year" = $3) AND exists (SELECT 1 FROM "bans" WHERE "bans"."user_id" = "users"."id") AND not exists (SELECT 1 FROM "logs" WHERE "level" = $4)` {
+		t.Error("[where] wrong sql result", sql)
+	}
+	if fmt.Sprint(args) != "[1 100 2023 error]" {
+		t.Error("[where] wrong args", args)
+	}
+
+	sql, args, _ = New("", pg).
+		FromSub(New("orders").Select("user_id", "sum(amount) AS total").Where("status", "=", 2).GroupBy("user_id").Query(), "t").
+		Select("user_id").
+		Where("total", ">", 1000).
+		Query().
+		Build()
+
+	if sql != `SELECT "user_id" FROM (SELECT "user_id",sum("amount") AS "total" FROM "orders" WHERE "status" = $1 GROUP BY "user_id") "t" WHERE "total" > $2` {
+		t.Error("[from] wrong sql result", sql)
+	}
+	if fmt.Sprint(args) != "[2 1000]" {
+		t.Error("[from] wrong args", args)
+	}
+
+	broken := New("orders").Select("user_id").Window("w", nil).Query()
+	outer := New("users", pg).Select("id").WhereIn("id", broken).Query()
+	if _, _, err := outer.Build(); !errors.Is(err, ErrInvalidExpression) {
+		t.Error("[sub error] expected the error of the subquery, got", err)
+	}
+	if err := outer.Validate(); !errors.Is(err, ErrInvalidExpression) {
+		t.Error("[sub error] expected Validate to report the subquery, got", err)
+	}
+	for name, b := range map[string]*SQLBulder{
+		"subquery": New("users", pg).Select("id").WhereExists(New("orders").Where("id", "=", 1).Delete()).Query(),
+		"cte":      New("t", pg).With("t", New("orders").Where("id", "=", 1).Delete()).Select("id").Query(),
+		"compound": New("users", pg).Select("id").Union(New("orders").Where("id", "=", 1).Delete()).Query(),
+	} {
+		if _, _, err := b.Build(); !errors.Is(err, ErrUnknownAction) {
+			t.Errorf("[%s] expected an error for a nested delete, got %v", name, err)
+		}
+	}
+}
+
+func TestSQLBuildHaving(t *testing.T) {
+	pg := NewBuilderOpt{Parameterized: true, Reuse: true, Dialect: PostgreSQL}
+
+	sql, args, _ := New("orders", pg).
+		Select("user_id", "count(*)").
+		Where("status", "=", 1).
+		GroupBy("user_id").
+		Having("count(*)", ">", 5).
+		OrHavings(func(w Wheres) Wheres {
+			return w.Where("sum(amount)", ">", 1000).Where("max(amount)", "<", 50)
+		}).
+		Limit(10).
+		Query().
+		Build()
+
+	if sql != `SELECT "user_id",count(*) FROM "orders" WHERE "status" = $1 GROUP BY "user_id" HAVING count(*) > $2 OR (sum("amount") > $3 AND max("amount") < $4) LIMIT 10` {
+		t.Error("[having] wrong sql result", sql)
+	}
+	if fmt.Sprint(args) != "[1 5 1000 50]" {
+		t.Error("[having] wrong args", args)
+	}
+}
+
+func TestSQLBuildClauseOrder(t *testing.T) {
+	clauses := []struct {
+		keyword string
+		apply   func(b *SQLBulder)
+	}{
+		{"INNER JOIN", func(b *SQLBulder) {
+			b.Join("orders o", func(w Wheres) Wheres { return w.Where("o.status", "=", 1) })
+		}},
+		{"WHERE", func(b *SQLBulder) { b.Where("a", "=", 2) }},
+		{"GROUP BY", func(b *SQLBulder) { b.GroupBy("c") }},
+		{"HAVING", func(b *SQLBulder) { b.Having("count(*)", ">", 3) }},
+		{"WINDOW", func(b *SQLBulder) { b.Window("w", NewWindow().PartitionBy("c")) }},
+		{"ORDER BY", func(b *SQLBulder) { b.OrderBy("a", OrderAsc) }},
+		{"LIMIT", func(b *SQLBulder) { b.Limit(10) }},
+		{"OFFSET", func(b *SQLBulder) { b.Offset(20) }},
+		{"FOR UPDATE", func(b *SQLBulder) { b.ForUpdate() }},
+	}
+
+	for _, dialect := range []Dialect{MySQL, PostgreSQL} {
+		for mask := 0; mask < 1<<len(clauses); mask++ {
+			b := New("test", NewBuilderOpt{Parameterized: true, Dialect: dialect}).Select("a", "count(*)")
+			applied := make([]string, 0, len(clauses))
+			for i, c := range clauses {
+				if mask&(1<<i) != 0 {
+					c.apply(b)
+					applied = append(applied, c.keyword)
+				}
+			}
+			sql, args, err := b.Query().Build()
+			if err != nil {
+				t.Fatalf("[%s %v] unexpected error: %v", dialect.Name(), applied, err)
+			}
+			prefix := "SELECT " + dialect.QuoteIdent("a") + ",count(*) FROM " + dialect.QuoteIdent("test")
+			if !strings.HasPrefix(sql, prefix) {
+				t.Errorf("[%s %v] wrong sql prefix: %s", dialect.Name(), applied, sql)
+			}
+			last := 0
+			for _, keyword := range applied {
+				idx := strings.Index(sql, " "+keyword+" ")
+				if idx == -1 && strings.HasSuffix(sql, " "+keyword) {
+					idx = len(sql) - len(keyword) - 1
+				}
+				if idx < last {
+					t.Errorf("[%s %v] %s out of order: %s", dialect.Name(), applied, keyword, sql)
+				}
+				last = idx
+			}
+			// args follow the join, where and having placeholders in order
+			want := make([]interface{}, 0, 3)
+			for i, v := range []interface{}{1, 2, 3} {
+				if mask&(1<<[]int{0, 1, 3}[i]) != 0 {
+					want = append(want, v)
+				}
+			}
+			if fmt.Sprint(args) != fmt.Sprint(want) {
+				t.Errorf("[%s %v] wrong args %v, want %v", dialect.Name(), applied, args, want)
+			}
+		}
+	}
+
+	if _, _, err := New("test", NewBuilderOpt{Dialect: SQLite}).Select("a").ForUpdate().Build(); err == nil {
+		t.Error("[sqlite] expected an error for FOR UPDATE")
+	}
+
+	mssql := NewBuilderOpt{Parameterized: true, Dialect: SQLServer}
+	if sql, _, _ := New("test", mssql).Select("a").Limit(10).Query().Build(); sql != "SELECT [a] FROM [test] ORDER BY (SELECT NULL) OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY" {
+		t.Error("[sqlserver] wrong sql result for a limit without order", sql)
+	}
+	if sql, _, _ := New("test", mssql).Select("a").OrderBy("a", OrderAsc).Offset(5).Query().Build(); sql != "SELECT [a] FROM [test] ORDER BY [a] ASC OFFSET 5 ROWS" {
+		t.Error("[sqlserver] wrong sql result for an ordered offset", sql)
+	}
+}
+
+func TestSQLBuildCompound(t *testing.T) {
+	pg := NewBuilderOpt{Parameterized: true, Reuse: true, Dialect: PostgreSQL}
+
+	partition := func(table string, status int) *SQLBulder {
+		return New(table).Select("id", "amount").Where("status", "=", status).Query()
+	}
+
+	sql, args, _ := New("orders_202301", pg).
+		Select("id", "amount").
+		Where("status", "=", 1).
+		Union(partition("orders_202302", 2)).
+		UnionAll(partition("orders_202303", 3).OrderBy("amount", OrderDesc).Limit(5)).
+		OrderBy("id", OrderAsc).
+		Limit(10).
+		Query().
+		Build()
+
+	if sql != `SELECT "id","amount" FROM "orders_202301" WHERE "status" = $1 UNION SELECT "id","amount" FROM "orders_202302" WHERE "status" = $2 UNION ALL (SELECT "id","amount" FROM "orders_202303" WHERE "status" = $3 ORDER BY "amount" DESC LIMIT 5) ORDER BY "id" ASC LIMIT 10` {
+		t.Error("[union] wrong sql result", sql)
+	}
+	if fmt.Sprint(args) != "[1 2 3]" {
+		t.Error("[union] wrong args", args)
+	}
+
+	sql, args, _ = New("a", pg).Select("id").Where("x", "=", 1).
+		Intersect(New("b").Select("id").Query()).
+		Except(New("c").Select("id").Where("y", "=", 2).Query()).
+		Query().
+		Build()
+
+	if sql != `SELECT "id" FROM "a" WHERE "x" = $1 INTERSECT SELECT "id" FROM "b" EXCEPT SELECT "id" FROM "c" WHERE "y" = $2` {
+		t.Error("[intersect] wrong sql result", sql)
+	}
+	if fmt.Sprint(args) != "[1 2]" {
+		t.Error("[intersect] wrong args", args)
+	}
+}
+
+func TestSQLBuildWith(t *testing.T) {
+	pg := NewBuilderOpt{Parameterized: true, Reuse: true, Dialect: PostgreSQL}
+
+	sql, args, _ := New("tree", pg).
+		WithRecursive("tree", []string{"id", "parent_id", "depth"},
+			New("categories").Select("id", "parent_id", "0").Where("id", "=", 1).Query(),
+			New("categories c").Select("c.id", "c.parent_id").SelectRaw(`"t"."depth" + 1`).
+				Join("tree t", func(w Wheres) Wheres {
+					return w.On("c.parent_id", "=", "t.id")
+				}).
+				Where("t.depth", "<", 5).
+				Query()).
+		With("active", New("products").Select("category_id").Where("status", "=", "on").Query()).
+		Select("tree.id").
+		Join("active a", func(w Wheres) Wheres {
+			return w.On("a.category_id", "=", "tree.id")
+		}).
+		Where("tree.depth", ">", 0).
+		Query().
+		Build()
+
+	if sql != `WITH RECURSIVE "tree"("id","parent_id","depth") AS (SELECT "id","parent_id",0 FROM "categories" WHERE "id" = $1 UNION ALL SELECT "c"."id","c"."parent_id","t"."depth" + 1 FROM "categories" "c" INNER JOIN "tree" "t" ON "c"."parent_id" = "t"."id" WHERE "t"."depth" < $2), "active" AS (SELECT "category_id" FROM "products" WHERE "status" = $3) SELECT "tree"."id" FROM "tree" INNER JOIN "active" "a" ON "a"."category_id" = "tree"."id" WHERE "tree"."depth" > $4` {
+		t.Error("[with] wrong sql result", sql)
+	}
+	if fmt.Sprint(args) != "[1 5 on 0]" {
+		t.Error("[with] wrong args", args)
+	}
+}
+
+func TestSQLBuildWindow(t *testing.T) {
+	pg := NewBuilderOpt{Parameterized: true, Reuse: true, Dialect: PostgreSQL}
+
+	sql, args, _ := New("salaries", pg).
+		Select("dept", "amount").
+		SelectOver("ROW_NUMBER()", NewWindow().PartitionBy("dept").OrderBy("amount", OrderDesc), "rn").
+		SelectOver("LAG(amount)", WindowRef("w"), "prev").
+		SelectOver("SUM(amount)", WindowRef("w").Rows(UnboundedPreceding, CurrentRow), "running").
+		SelectOver("AVG(amount)", NewWindow().OrderBy("paid_at", OrderAsc).Range(Preceding(7), Following(7)), "").
+		Where("year", "=", 2023).
+		Window("w", NewWindow().PartitionBy("dept").OrderBy("paid_at", OrderAsc)).
+		OrderBy("dept", OrderAsc).
+		Query().
+		Build()
+
+	if sql != `SELECT "dept","amount",ROW_NUMBER() OVER (PARTITION BY "dept" ORDER BY "amount" DESC) AS "rn",LAG("amount") OVER "w" AS "prev",SUM("amount") OVER ("w" ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW) AS "running",AVG("amount") OVER (ORDER BY "paid_at" ASC RANGE BETWEEN 7 PRECEDING AND 7 FOLLOWING) FROM "salaries" WHERE "year" = $1 WINDOW "w" AS (PARTITION BY "dept" ORDER BY "paid_at" ASC) ORDER BY "dept" ASC` {
+		t.Error("[window] wrong sql result", sql)
+	}
+	if fmt.Sprint(args) != "[2023]" {
+		t.Error("[window] wrong args", args)
+	}
+
+	_, _, err := New("salaries", NewBuilderOpt{Parameterized: true, Dialect: SQLServer}).
+		SelectOver("AVG(amount)", NewWindow().OrderBy("paid_at", OrderAsc).Range(Preceding(7), CurrentRow), "avg").
+		Query().
+		Build()
+	if err == nil {
+		t.Error("[window] expected an error for sqlserver range offsets")
+	}
+
+	sql, _, err = New("salaries", pg).Select("dept").SelectOver("ROW_NUMBER()", nil, "rn").Query().Build()
+	if err != nil || sql != `SELECT "dept",ROW_NUMBER() OVER () AS "rn" FROM "salaries"` {
+		t.Error("[window] wrong sql result for an empty window", sql, err)
+	}
+	if _, _, err = New("salaries", pg).Select("dept").Window("w", nil).Query().Build(); !errors.Is(err, ErrInvalidExpression) {
+		t.Error("[window] expected ErrInvalidExpression for a nil window, got", err)
+	}
+	if _, _, err = New("salaries", pg).SelectOver("1; DROP TABLE x; --", nil, "a").Query().Build(); !errors.Is(err, ErrInvalidExpression) {
+		t.Error("[window] expected ErrInvalidExpression for an invalid window function, got", err)
+	}
+
+	sql, _, err = New("salaries", pg).
+		SelectOver("NTILE(4)", nil, "q").
+		SelectOverRaw("LAG(amount, 1, 0)", nil, "prev").
+		Query().
+		Build()
+	if err != nil || sql != `SELECT NTILE(4) OVER () AS "q",LAG(amount, 1, 0) OVER () AS "prev" FROM "salaries"` {
+		t.Error("[window] wrong sql result for a raw window function", sql, err)
+	}
+}
+
+func TestSQLBuildColumnOrder(t *testing.T) {
+	opt := NewBuilderOpt{Parameterized: true, Reuse: true}
+	row := map[string]interface{}{"d": 4, "a": 1, "c": 3, "b": 2}
+
+	for i := 0; i < 20; i++ {
+		sql, args, _ := New("test", opt).Insert(row).Build()
+		if sql != "INSERT INTO `test` (`a`,`b`,`c`,`d`) VALUES (?,?,?,?)" || fmt.Sprint(args) != "[1 2 3 4]" {
+			t.Fatal("[insert] unstable sql result", sql, args)
+		}
+
+		sql, args, _ = New("test", opt).Where("id", "=", 9).Update(row).Build()
+		if sql != "UPDATE `test` SET `a` = ?,`b` = ?,`c` = ?,`d` = ? WHERE `id` = ?" || fmt.Sprint(args) != "[1 2 3 4 9]" {
+			t.Fatal("[update] unstable sql result", sql, args)
+		}
+	}
+
+	sql, args, _ := New("test", opt).Columns("d", "b", "x").BatchInsert([]map[string]interface{}{
+		row,
+		{"d": 8, "a": 5, "c": 7, "b": 6},
+	}).Build()
+	if sql != "INSERT INTO `test` (`d`,`b`,`a`,`c`) VALUES (?,?,?,?),(?,?,?,?)" {
+		t.Error("[batch insert] wrong sql result", sql)
+	}
+	if fmt.Sprint(args) != "[4 2 1 3 8 6 5 7]" {
+		t.Error("[batch insert] wrong args", args)
+	}
+}
+
+func TestSQLBuildNull(t *testing.T) {
+	pg := NewBuilderOpt{Parameterized: true, Reuse: true, Dialect: PostgreSQL}
+	var deleted *int
+
+	sql, args, _ := New("users", pg).
+		Select("id").
+		Where("a", "=", 1).
+		Where("deleted_at", "=", nil).
+		Where("b", "!=", nil).
+		Where("c", "=", deleted).
+		WhereNull("d").
+		OrWhereNotNull("e").
+		Wheres(func(w Wheres) Wheres {
+			return w.WhereNull("f").OrWhereNotNull("g")
+		}).
+		Where("h", "=", 2).
+		Query().
+		Build()
+
+	if sql != `SELECT "id" FROM "users" WHERE "a" = $1 AND "deleted_at" is null AND "b" is not null AND "c" is null AND "d" is null OR "e" is not null AND ("f" is null OR "g" is not null) AND "h" = $2` {
+		t.Error("[where] wrong sql result", sql)
+	}
+	if fmt.Sprint(args) != "[1 2]" {
+		t.Error("[where] wrong args", args)
+	}
+
+	sql, args, _ = New("users", pg).BatchInsert([]map[string]interface{}{
+		{"a": 1, "b": nil},
+		{"a": nil, "b": deleted},
+	}).Build()
+
+	if sql != `INSERT INTO "users" ("a","b") VALUES ($1,NULL),(NULL,NULL)` {
+		t.Error("[insert] wrong sql result", sql)
+	}
+	if fmt.Sprint(args) != "[1]" {
+		t.Error("[insert] wrong args", args)
+	}
+
+	sql, args, _ = New("users", pg).Where("id", "=", 3).Update(map[string]interface{}{
+		"a": nil,
+		"b": 2,
+	}).Build()
+
+	if sql != `UPDATE "users" SET "a" = NULL,"b" = $1 WHERE "id" = $2` {
+		t.Error("[update] wrong sql result", sql)
+	}
+	if fmt.Sprint(args) != "[2 3]" {
+		t.Error("[update] wrong args", args)
+	}
+}
+
+func TestSQLBuildOperators(t *testing.T) {
+	build := func(d Dialect) (string, []interface{}, error) {
+		return New("users", NewBuilderOpt{Parameterized: true, Dialect: d}).
+			Select("id").
+			Where("a", OpNe, 1).
+			Where("b", "LIKE", "jo%").
+			WhereNotLike("c", "%x").
+			WhereContains("d", "50%_off").
+			Where("e", OpBetween, []int{1, 10}).
+			WhereNotBetween("f", "a", "m").
+			Where("g", OpRegexp, "^ab").
+			Where("h", OpNullSafeEq, 2).
+			Where("i", OpIsDistinctFrom, 3).
+			Query().
+			Build()
+	}
+
+	cases := map[Dialect]string{
+		MySQL:      "SELECT `id` FROM `users` WHERE `a` != ? AND `b` like ? AND `c` not like ? AND `d` like ? AND `e` between ? and ? AND `f` not between ? and ? AND `g` regexp ? AND `h` <=> ? AND not (`i` <=> ?)",
+		PostgreSQL: `SELECT "id" FROM "users" WHERE "a" != $1 AND "b" like $2 AND "c" not like $3 AND "d" like $4 AND "e" between $5 and $6 AND "f" not between $7 and $8 AND "g" ~ $9 AND "h" is not distinct from $10 AND "i" is distinct from $11`,
+		SQLite:     `SELECT "id" FROM "users" WHERE "a" != ? AND "b" like ? escape '\' AND "c" not like ? escape '\' AND "d" like ? escape '\' AND "e" between ? and ? AND "f" not between ? and ? AND "g" regexp ? AND "h" is ? AND "i" is not ?`,
+	}
+	for d, want := range cases {
+		sql, args, err := build(d)
+		if err != nil || sql != want {
+			t.Errorf("[%s] wrong sql result: %s %v", d.Name(), sql, err)
+		}
+		if fmt.Sprint(args) != `[1 jo% %x %50\%\_off% 1 10 a m ^ab 2 3]` {
+			t.Errorf("[%s] wrong args %v", d.Name(), args)
+		}
+	}
+
+	if _, _, err := build(SQLServer); err == nil {
+		t.Error("[sqlserver] expected an error for regexp")
+	}
+	if _, _, err := New("users").Select("id").Where("a", OpBetween, 1).Query().Build(); err == nil {
+		t.Error("expected an error for a between with one value")
+	}
+	for _, bounds := range []interface{}{[]int{}, []int{1}} {
+		if _, _, err := New("users").Select("id").Where("a", OpBetween, bounds).Query().Build(); !errors.Is(err, ErrInvalidExpression) {
+			t.Error("expected ErrInvalidExpression for a between with bounds", bounds, err)
+		}
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	if got := EscapeLike(`100%_a\b`); got != `100\%\_a\\b` {
+		t.Error("wrong escaped pattern", got)
+	}
+}
+
+func TestSQLBuildStrictOperators(t *testing.T) {
+	_, _, err := New("users").Select("id").Where("a", "= 1 OR 1=1 --", 1).Query().Build()
+	var opErr *OperatorError
+	if !errors.As(err, &opErr) || opErr.Operation != "= 1 or 1=1 --" {
+		t.Error("expected an operator error, got", err)
+	}
+
+	_, _, err = New("users").Select("id").Wheres(func(w Wheres) Wheres {
+		return w.Where("a", "@@", 1)
+	}).Query().Build()
+	if !errors.As(err, &opErr) {
+		t.Error("expected an operator error for a nested condition, got", err)
+	}
+
+	sql, _, err := New("users", NewBuilderOpt{Parameterized: true, AllowUnknownOperators: true}).
+		Select("id").Where("a", "@@", 1).Query().Build()
+	if err != nil || sql != "SELECT `id` FROM `users` WHERE `a` @@ ?" {
+		t.Error("unknown operator not rendered", sql, err)
+	}
+
+	RegisterOperator("@>", func(d Dialect, field string, operands []string) (string, error) {
+		if d.Name() != PostgreSQL.Name() {
+			return "", errors.New("@> needs postgres")
+		}
+		return field + " @> " + operands[0], nil
+	})
+	sql, _, err = New("docs", NewBuilderOpt{Parameterized: true, Dialect: PostgreSQL}).
+		Select("id").Where("tags", "@>", "{a}").Query().Build()
+	if err != nil || sql != `SELECT "id" FROM "docs" WHERE "tags" @> $1` {
+		t.Error("custom operator not rendered", sql, err)
+	}
+	if _, _, err = New("docs").Select("id").Where("tags", "@>", "{a}").Query().Build(); err == nil {
+		t.Error("expected the custom operator error")
+	}
+}
+
+func TestSQLBuildIdentifiers(t *testing.T) {
+	pg := NewBuilderOpt{Parameterized: true, Reuse: true, Dialect: PostgreSQL}
+
+	sql, _, err := New("shop.orders AS o", pg).
+		Select("o.*", "count(DISTINCT o.user_id) AS buyers", "max(shop.orders.amount)").
+		SelectRaw("now() - interval '1 day'").
+		GroupBy("o.day").
+		GroupByRaw("1").
+		OrderBy("buyers", OrderDesc).
+		OrderByRaw("o.day NULLS LAST").
+		Query().
+		Build()
+	if err != nil || sql != `SELECT "o".*,count(DISTINCT "o"."user_id") AS "buyers",max("shop"."orders"."amount"),now() - interval '1 day' FROM "shop"."orders" AS "o" GROUP BY "o"."day",1 ORDER BY "buyers" DESC,o.day NULLS LAST` {
+		t.Error("[qualified] wrong sql result", sql, err)
+	}
+
+	injections := map[string]*SQLBulder{
+		"order by": New("users").Select("id").OrderBy("id; DROP TABLE users", OrderAsc).Query(),
+		"order":    New("users").Select("id").OrderBy("id", OrderEnum("ASC, (SELECT 1)")).Query(),
+		"select":   New("users").Select("id, password").Query(),
+		"where":    New("users").Select("id").Where("1=1 OR id", "=", 1).Query(),
+		"group by": New("users").Select("id").GroupBy("id) UNION SELECT (1").Query(),
+		"table":    New("users u JOIN secrets s").Select("id").Query(),
+		"index":    New("users").Select("id").ForceIndex("a) UNION SELECT 1 --").Query(),
+		"insert":   New("users").Insert(map[string]interface{}{"a`,`b": 1}),
+		"upsert":   New("users").Insert(map[string]interface{}{"a": 1}).OnDuplicateUpdateKeys("a = 1, b"),
+	}
+	for name, b := range injections {
+		if sql, _, err := b.Build(); err == nil {
+			t.Errorf("[%s] expected an identifier error, got %s", name, sql)
+		}
+	}
+
+	sql, args, err := New("orders", pg).
+		Distinct().
+		Select("user_id").
+		Where("state", "=", "paid").
+		WhereRaw("amount * rate > ? OR vip", 100).
+		GroupBy("user_id").
+		HavingRaw("count(*) > ?", 2).
+		OrderBy("user_id", "asc").
+		Query().
+		Build()
+	if err != nil || sql != `SELECT DISTINCT "user_id" FROM "orders" WHERE "state" = $1 AND (amount * rate > $2 OR vip) GROUP BY "user_id" HAVING (count(*) > $3) ORDER BY "user_id" ASC` {
+		t.Error("[raw] wrong sql result", sql, err)
+	}
+	if fmt.Sprint(args) != "[paid 100 2]" {
+		t.Error("[raw] wrong args", args)
+	}
+}
+
+func TestSQLBuildExpr(t *testing.T) {
+	pg := NewBuilderOpt{Parameterized: true, Reuse: true, Dialect: PostgreSQL}
+
+	sql, args, err := New("users", pg).
+		SelectExpr(NewExpr("coalesce(nick, ?) AS label", "anon"), NewExpr(`data ?? 'a?b' AND "x?" = ?`, Col("users.id"))).
+		Where("score", ">", NewExpr("avg_score * ?", 1.5)).
+		OrderByExpr(NewExpr("abs(age - ?)", 30), OrderAsc).
+		Limit(5).
+		Query().
+		Build()
+	if err != nil || sql != `SELECT coalesce(nick, $1) AS label,data ? 'a?b' AND "x?" = "users"."id" FROM "users" WHERE "score" > avg_score * $2 ORDER BY abs(age - $3) ASC LIMIT 5` {
+		t.Error("[select] wrong sql result", sql, err)
+	}
+	if fmt.Sprint(args) != "[anon 1.5 30]" {
+		t.Error("[select] wrong args", args)
+	}
+
+	sql, args, err = New("users", pg).Where("id", "=", 3).Update(map[string]interface{}{
+		"a": NewExpr("a + ?", 1),
+		"b": 2,
+		"c": UpdateRaw{Expr: "c * 2"},
+	}).Build()
+	if err != nil || sql != `UPDATE "users" SET "a" = a + $1,"b" = $2,"c" = c * 2 WHERE "id" = $3` {
+		t.Error("[update] wrong sql result", sql, err)
+	}
+	if fmt.Sprint(args) != "[1 2 3]" {
+		t.Error("[update] wrong args", args)
+	}
+
+	for d, want := range map[Dialect]string{PostgreSQL: "[o''x d]", MySQL: `[o\'x d]`} {
+		_, args, err = New("users", NewBuilderOpt{Dialect: d}).Select("a").
+			Where("b", "=", Coalesce(NewExpr("lower(?)", "o'x"), "d")).Query().Build()
+		if err != nil || fmt.Sprint(args) != want {
+			t.Error("[escape] nested string escaped more than once", d.Name(), args, err)
+		}
+	}
+
+	update := map[string]interface{}{"a": UpdateRaw{Expr: "a + ?", Args: []interface{}{5}}, "b": 2}
+	sql, args, err = New("users", pg).Where("id", "=", 3).Update(update).Build()
+	if err != nil || sql != `UPDATE "users" SET "a" = a + $1,"b" = $2 WHERE "id" = $3` || fmt.Sprint(args) != "[5 2 3]" {
+		t.Error("[update raw] wrong sql result", sql, args, err)
+	}
+	sql, args, err = New("users", NewBuilderOpt{Parameterized: true, Dialect: SQLServer}).Where("id", "=", 3).Update(update).Build()
+	if err != nil || sql != `UPDATE [users] SET [a] = a + @p1,[b] = @p2 WHERE [id] = @p3` || fmt.Sprint(args) != "[5 2 3]" {
+		t.Error("[update raw] wrong sql result", sql, args, err)
+	}
+
+	sql, args, err = New("users", NewBuilderOpt{Parameterized: true}).Insert(map[string]interface{}{
+		"a": 1,
+		"b": NewExpr("concat(?, 'x\\'?')", "y"),
+	}).Build()
+	if err != nil || sql != "INSERT INTO `users` (`a`,`b`) VALUES (?,concat(?, 'x\\'?'))" {
+		t.Error("[insert] wrong sql result", sql, err)
+	}
+	if fmt.Sprint(args) != "[1 y]" {
+		t.Error("[insert] wrong args", args)
+	}
+
+	if _, _, err = New("users").Select("id").Where("a", "=", NewExpr("? + ?", 1)).Query().Build(); err == nil {
+		t.Error("expected an error for missing args")
+	}
+	if _, _, err = New("users").SelectExpr(NewExpr("abs(a)", 1)).Query().Build(); err == nil {
+		t.Error("expected an error for extra args")
+	}
+}
+
+func TestSQLBuildArithmetic(t *testing.T) {
+	opt := NewBuilderOpt{Parameterized: true, Reuse: true}
+
+	sql, args, err := New("products", opt).
+		Where("id", "=", 7).
+		Where("stock", ">=", 2).
+		Update(map[string]interface{}{
+			"price":  Col("price").Mul(Col("rate")).Add(1),
+			"rating": Greatest(Col("rating"), 0),
+			"nick":   Coalesce(Col("nick"), "none"),
+		}).
+		Decrement("stock", 2).
+		Increment("sold", 2).
+		Build()
+	if err != nil || sql != "UPDATE `products` SET `nick` = COALESCE(`nick`, ?),`price` = (`price` * `rate`) + ?,`rating` = GREATEST(`rating`, ?),`sold` = `sold` + ?,`stock` = `stock` - ? WHERE `id` = ? AND `stock` >= ?" {
+		t.Error("[update] wrong sql result", sql, err)
+	}
+	if fmt.Sprint(args) != "[none 1 0 2 2 7 2]" {
+		t.Error("[update] wrong args", args)
+	}
+
+	sql, args, _ = New("products", opt).Where("id", "=", 7).Increment("sold", 2).
+		Update(map[string]interface{}{"price": 3}).Build()
+	if sql != "UPDATE `products` SET `price` = ?,`sold` = `sold` + ? WHERE `id` = ?" || fmt.Sprint(args) != "[3 2 7]" {
+		t.Error("[update] expected Update to keep the increment", sql, args)
+	}
+
+	sql, args, err = New("accounts", NewBuilderOpt{Parameterized: true, Dialect: SQLite}).
+		Where("id", "=", 1).
+		Update(map[string]interface{}{"balance": Least(Col("balance").Sub(5), Col("limit"))}).
+		Build()
+	if err != nil || sql != `UPDATE "accounts" SET "balance" = min("balance" - ?, "limit") WHERE "id" = ?` {
+		t.Error("[sqlite] wrong sql result", sql, err)
+	}
+	if fmt.Sprint(args) != "[5 1]" {
+		t.Error("[sqlite] wrong args", args)
+	}
+
+	if _, _, err = New("products").Where("id", "=", 1).Increment("a; --", 1).Build(); err == nil {
+		t.Error("expected an identifier error")
+	}
+}
+
+func TestSQLBuildBatchInsert(t *testing.T) {
+	opt := NewBuilderOpt{Parameterized: true, Reuse: true}
+	rows := []map[string]interface{}{
+		{"a": 1, "b": 2},
+		{"a": 3, "c": nil},
+		{"b": 4, "a": 5},
+	}
+
+	_, _, err := New("test", opt).BatchInsert(rows).Build()
+	if !errors.Is(err, ErrInconsistentBatchColumns) {
+		t.Error("[strict] expected an inconsistent columns error, got", err)
+	}
+
+	sql, args, err := New("test", opt).BatchInsert(rows).Batch(BatchFillDefault).Build()
+	if err != nil || sql != "INSERT INTO `test` (`a`,`b`,`c`) VALUES (?,?,DEFAULT),(?,DEFAULT,NULL),(?,?,DEFAULT)" {
+		t.Error("[default] wrong sql result", sql, err)
+	}
+	if fmt.Sprint(args) != "[1 2 3 5 4]" {
+		t.Error("[default] wrong args", args)
+	}
+
+	sql, _, err = New("test", NewBuilderOpt{Parameterized: true, Dialect: PostgreSQL}).BatchInsert(rows).Batch(BatchFillNull).Build()
+	if err != nil || sql != `INSERT INTO "test" ("a","b","c") VALUES ($1,$2,NULL),($3,NULL,NULL),($4,$5,NULL)` {
+		t.Error("[null] wrong sql result", sql, err)
+	}
+
+	if _, _, err = New("test", NewBuilderOpt{Dialect: SQLite}).BatchInsert(rows).Batch(BatchFillDefault).Build(); !errors.Is(err, ErrUnsupported) {
+		t.Error("[sqlite] expected an unsupported error, got", err)
+	}
+
+	grouped := New("test", NewBuilderOpt{Parameterized: true, Dialect: PostgreSQL}).BatchInsert(rows).Batch(BatchGroup)
+	if _, _, err = grouped.Build(); !errors.Is(err, ErrMultipleStatements) {
+		t.Error("[group] expected a multiple statements error, got", err)
+	}
+	stmts, err := grouped.BuildAll()
+	if err != nil || len(stmts) != 2 {
+		t.Fatal("[group] wrong statements", stmts, err)
+	}
+	if stmts[0].SQL != `INSERT INTO "test" ("a","b") VALUES ($1,$2),($3,$4)` || fmt.Sprint(stmts[0].Args) != "[1 2 5 4]" {
+		t.Error("[group] wrong first statement", stmts[0])
+	}
+	if stmts[1].SQL != `INSERT INTO "test" ("a","c") VALUES ($1,NULL)` || fmt.Sprint(stmts[1].Args) != "[3]" {
+		t.Error("[group] wrong second statement", stmts[1])
+	}
+	if err = grouped.Validate(); err != nil {
+		t.Error("[group] unexpected problems", err)
+	}
+
+	stmts, err = New("test", opt).BatchInsert(rows).Batch(BatchGroup).OnDuplicateUpdateKeys("a", "b").BuildAll()
+	if err != nil || len(stmts) != 2 ||
+		stmts[0].SQL != "INSERT INTO `test` (`a`,`b`) VALUES (?,?),(?,?) ON DUPLICATE KEY UPDATE `a` = VALUES(`a`), `b` = VALUES(`b`)" ||
+		stmts[1].SQL != "INSERT INTO `test` (`a`,`c`) VALUES (?,NULL) ON DUPLICATE KEY UPDATE `a` = VALUES(`a`)" {
+		t.Error("[group upsert] wrong statements", stmts, err)
+	}
+	if _, err = New("test", opt).BatchInsert(rows).Batch(BatchGroup).OnDuplicateUpdateKeys("b").BuildAll(); !errors.Is(err, ErrInconsistentBatchColumns) {
+		t.Error("[group upsert] expected an error for a group without the columns to update, got", err)
+	}
+
+	if _, _, err = New("test", opt).BatchInsert(rows).Batch(BatchMode("x); DROP TABLE t; --")).Build(); !errors.Is(err, ErrInvalidExpression) {
+		t.Error("[mode] expected an error for an unknown batch mode, got", err)
+	}
+
+	stmts, err = New("test", opt).Select("a").Query().BuildAll()
+	if err != nil || len(stmts) != 1 || stmts[0].SQL != "SELECT `a` FROM `test`" {
+		t.Error("[select] wrong statements", stmts, err)
+	}
+}
+
+func TestSQLBuildChunk(t *testing.T) {
+	opt := NewBuilderOpt{Parameterized: true}
+	rows := make([]map[string]interface{}, 0, 7)
+	for i := 0; i < 7; i++ {
+		rows = append(rows, map[string]interface{}{"a": i, "b": "xxxxxxxxxx"})
+	}
+	count := func(limits ChunkLimits) []int {
+		stmts, err := New("test", opt).BatchInsert(rows).Chunk(limits).BuildAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes := make([]int, 0, len(stmts))
+		for _, s := range stmts {
+			sizes = append(sizes, len(s.Args)/2)
+		}
+		return sizes
+	}
+
+	if got := fmt.Sprint(count(ChunkLimits{MaxRows: 3})); got != "[3 3 1]" {
+		t.Error("[rows] wrong chunks", got)
+	}
+	if got := fmt.Sprint(count(ChunkLimits{MaxPlaceholders: 9})); got != "[4 3]" {
+		t.Error("[placeholders] wrong chunks", got)
+	}
+	if got := fmt.Sprint(count(ChunkLimits{MaxBytes: 100})); got != "[2 2 2 1]" {
+		t.Error("[bytes] wrong chunks", got)
+	}
+	if got := fmt.Sprint(count(ChunkLimits{MaxBytes: 1})); got != "[1 1 1 1 1 1 1]" {
+		t.Error("[oversized] wrong chunks", got)
+	}
+
+	if _, _, err := New("test", opt).BatchInsert(rows).Chunk(ChunkLimits{MaxRows: 3}).Build(); !errors.Is(err, ErrMultipleStatements) {
+		t.Error("expected a multiple statements error, got", err)
+	}
+	if sql, args, err := New("test", opt).BatchInsert(rows[:2]).Chunk(ChunkLimits{MaxRows: 3}).Build(); err != nil ||
+		sql != "INSERT INTO `test` (`a`,`b`) VALUES (?,?),(?,?)" || len(args) != 4 {
+		t.Error("[single chunk] wrong sql result", sql, err)
+	}
+
+	stmts, err := New("test", NewBuilderOpt{Parameterized: true, Dialect: PostgreSQL}).BatchInsert(rows).
+		OnConflict("a").DoUpdateSet(map[string]interface{}{"b": "y"}).
+		DoUpdateWhere(func(w Wheres) Wheres { return w.Where("test.b", "<>", "z") }).
+		Chunk(ChunkLimits{MaxPlaceholders: 5}).BuildAll()
+	if err != nil || len(stmts) != 7 {
+		t.Fatal("[upsert] wrong chunks", stmts, err)
+	}
+	for _, stmt := range stmts {
+		if len(stmt.Args) > 5 {
+			t.Error("[upsert] chunk over the placeholder limit", stmt)
+		}
+	}
+}
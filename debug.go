@@ -0,0 +1,7 @@
+//go:build sqlbuilder_debug
+
+package builder
+
+// debugLifecycle makes the use of a released builder panic, and keeps
+// released builders out of the pool so that every such use is caught.
+const debugLifecycle = true
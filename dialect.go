@@ -0,0 +1,476 @@
+package builder
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Dialect renders the database specific parts of a statement. Builders
+// delegate placeholders, quoting, row limiting, index hints and upserts to
+// it, custom dialects can be made available by name with RegisterDialect.
+type Dialect interface {
+	// Name is the name the dialect is registered with.
+	Name() string
+	// Placeholder returns the bind parameter for the index-th argument of
+	// the statement, index starts from 1.
+	Placeholder(index int) string
+	// QuoteIdent quotes a single identifier such as a column name.
+	QuoteIdent(ident string) string
+	// LimitOffset renders the row limiting clause of a select, a value of
+	// -1 means the limit or offset is not set.
+	LimitOffset(limit Limit, offset Offset) string
+	// Lock renders the locking clause placed at the end of a select.
+	Lock(mode LockMode) (string, error)
+	// IndexHint renders the hint placed after the table name of a select.
+	IndexHint(index ForceIndex) string
+	// InsertIgnore returns the keyword placed after INSERT and the clause
+	// appended to the statement to skip rows that conflict.
+	InsertIgnore() (keyword, clause string, err error)
+	// Upsert renders the clause appended to an INSERT to update the rows
+	// that conflict.
+	Upsert(c *ConflictClause) (string, error)
+}
+
+// Features lists where a dialect departs from standard sql, which the zero
+// value describes.
+type Features struct {
+	// BackslashEscapes quotes the string literals of a non parameterized
+	// builder with double quotes and escapes them with backslashes, the
+	// expressions it renders may escape quotes with a backslash as well.
+	BackslashEscapes bool
+	// LikeBackslashEscape makes backslash the default escape character of
+	// LIKE, otherwise ESCAPE '\' is rendered for EscapeLike.
+	LikeBackslashEscape bool
+	// WriteJoins accepts joins in UPDATE and DELETE.
+	WriteJoins bool
+	// NoDefaultValues rejects DEFAULT in the rows of an insert.
+	NoDefaultValues bool
+	// ImplicitRecursiveWith has no RECURSIVE keyword, a cte referring to
+	// itself is recursive.
+	ImplicitRecursiveWith bool
+	// LimitNeedsOrder only limits the rows of a select with an ORDER BY,
+	// ORDER BY (SELECT NULL) is rendered when it has none.
+	LimitNeedsOrder bool
+	// NoRangeOffsets only accepts unbounded and current row bounds in
+	// RANGE frames.
+	NoRangeOffsets bool
+	// Functions renames standard functions such as GREATEST.
+	Functions map[string]string
+}
+
+// FeatureDialect is implemented by the dialects which depart from standard
+// sql, see Features.
+type FeatureDialect interface {
+	Dialect
+	Features() Features
+}
+
+// OperatorDialect is implemented by the dialects which render some
+// operators their own way, before the renderer registered for them.
+type OperatorDialect interface {
+	Dialect
+	// RenderOperator renders the condition, handled is false for the
+	// operators left to their registered renderer.
+	RenderOperator(op Operation, field string, operands []string) (sql string, handled bool, err error)
+}
+
+// _features returns the features of d, standard sql unless d says
+// otherwise.
+func _features(d Dialect) Features {
+	if fd, ok := d.(FeatureDialect); ok {
+		return fd.Features()
+	}
+	return Features{}
+}
+
+// ConflictClause describes how an INSERT handles conflicting rows.
+type ConflictClause struct {
+	// Columns is the conflict target, the columns of a unique index.
+	Columns []string
+	// Constraint names the conflict target instead of Columns.
+	Constraint string
+	// DoNothing skips the conflicting rows instead of updating them.
+	DoNothing bool
+	// Update lists the columns overwritten with the inserted values.
+	Update []string
+	// Set holds the other assignments of the update, rendered already such
+	// as `"n" = "t"."n" + excluded."n"`.
+	Set []string
+	// Where is the rendered condition limiting the rows updated.
+	Where string
+}
+
+// ExcludedDialect is implemented by the dialects whose upserts can refer to
+// the row proposed for insertion, which Excluded and DoUpdate need.
+type ExcludedDialect interface {
+	Dialect
+	// ExcludedColumn refers to the quoted column of the proposed row.
+	ExcludedColumn(column string) string
+}
+
+var (
+	MySQL Dialect = mysqlDialect{}
+	// MySQL8 is MySQL 8.0.20 or later, its upserts refer to the inserted
+	// row through a row alias rather than the deprecated VALUES().
+	MySQL8     Dialect = mysql8Dialect{}
+	PostgreSQL Dialect = postgresDialect{}
+	SQLite     Dialect = sqliteDialect{}
+	SQLServer  Dialect = sqlserverDialect{}
+)
+
+var (
+	dialectsMu sync.RWMutex
+	dialects   = map[string]Dialect{}
+)
+
+func init() {
+	for _, d := range []Dialect{MySQL, MySQL8, PostgreSQL, SQLite, SQLServer} {
+		RegisterDialect(d)
+	}
+}
+
+// RegisterDialect makes d available by its name, replacing any dialect
+// registered before with the same name.
+func RegisterDialect(d Dialect) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	dialects[d.Name()] = d
+}
+
+// GetDialect returns the dialect registered with name.
+func GetDialect(name string) (Dialect, bool) {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	d, ok := dialects[name]
+	return d, ok
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string {
+	return "mysql"
+}
+
+func (mysqlDialect) Placeholder(index int) string {
+	return "?"
+}
+
+func (mysqlDialect) QuoteIdent(ident string) string {
+	return _quoteIdent(ident, "`", "`")
+}
+
+func (mysqlDialect) LimitOffset(limit Limit, offset Offset) string {
+	return _limitOffset(limit, offset)
+}
+
+func (mysqlDialect) Lock(mode LockMode) (string, error) {
+	return string(mode), nil
+}
+
+func (mysqlDialect) IndexHint(index ForceIndex) string {
+	return index.String()
+}
+
+func (mysqlDialect) Features() Features {
+	return Features{BackslashEscapes: true, LikeBackslashEscape: true, WriteJoins: true}
+}
+
+func (mysqlDialect) RenderOperator(op Operation, field string, operands []string) (string, bool, error) {
+	distinct, ok := _nullSafe(op)
+	switch {
+	case !ok:
+		return "", false, nil
+	case distinct:
+		return "not " + _wrapBracket(field+" <=> "+operands[0]), true, nil
+	}
+	return field + " <=> " + operands[0], true, nil
+}
+
+func (mysqlDialect) InsertIgnore() (string, string, error) {
+	return "IGNORE", "", nil
+}
+
+// Upsert renders ON DUPLICATE KEY UPDATE, which checks every unique key of
+// the table: the conflict target only names the column DoNothing assigns
+// to itself.
+func (d mysqlDialect) Upsert(c *ConflictClause) (string, error) {
+	sets, err := _duplicateKeyAssignments(d, c)
+	if err != nil {
+		return "", err
+	}
+	return "ON DUPLICATE KEY UPDATE " + sets, nil
+}
+
+func (mysqlDialect) ExcludedColumn(column string) string {
+	return "VALUES(" + column + ")"
+}
+
+type mysql8Dialect struct {
+	mysqlDialect
+}
+
+func (mysql8Dialect) Name() string {
+	return "mysql8"
+}
+
+func (d mysql8Dialect) Upsert(c *ConflictClause) (string, error) {
+	sets, err := _duplicateKeyAssignments(d, c)
+	if err != nil {
+		return "", err
+	}
+	return "AS new ON DUPLICATE KEY UPDATE " + sets, nil
+}
+
+func (mysql8Dialect) ExcludedColumn(column string) string {
+	return "new." + column
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return "postgres"
+}
+
+func (postgresDialect) Placeholder(index int) string {
+	return "$" + strconv.Itoa(index)
+}
+
+func (postgresDialect) QuoteIdent(ident string) string {
+	return _quoteIdent(ident, `"`, `"`)
+}
+
+func (postgresDialect) LimitOffset(limit Limit, offset Offset) string {
+	return _limitOffset(limit, offset)
+}
+
+func (postgresDialect) Lock(mode LockMode) (string, error) {
+	return string(mode), nil
+}
+
+func (postgresDialect) IndexHint(index ForceIndex) string {
+	// postgres has no index hints, the planner decides on its own
+	return ""
+}
+
+func (postgresDialect) Features() Features {
+	return Features{LikeBackslashEscape: true}
+}
+
+func (postgresDialect) RenderOperator(op Operation, field string, operands []string) (string, bool, error) {
+	if op == OpRegexp {
+		return field + " ~ " + operands[0], true, nil
+	}
+	return "", false, nil
+}
+
+func (postgresDialect) InsertIgnore() (string, string, error) {
+	return "", "ON CONFLICT DO NOTHING", nil
+}
+
+func (d postgresDialect) Upsert(c *ConflictClause) (string, error) {
+	if len(c.Columns) == 0 && c.Constraint == "" && !c.DoNothing {
+		return "", _wrapError(ErrUnsupported, "postgres upsert requires a conflict target")
+	}
+	return _onConflict(d, c)
+}
+
+func (postgresDialect) ExcludedColumn(column string) string {
+	return "excluded." + column
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return "sqlite"
+}
+
+func (sqliteDialect) Placeholder(index int) string {
+	return "?"
+}
+
+func (sqliteDialect) QuoteIdent(ident string) string {
+	return _quoteIdent(ident, `"`, `"`)
+}
+
+func (sqliteDialect) LimitOffset(limit Limit, offset Offset) string {
+	if limit == -1 && offset != -1 {
+		// sqlite does not accept OFFSET without LIMIT
+		return "LIMIT -1 " + offset.String()
+	}
+	return _limitOffset(limit, offset)
+}
+
+func (sqliteDialect) Lock(mode LockMode) (string, error) {
+	return "", _wrapError(ErrUnsupported, "sqlite does not support "+string(mode))
+}
+
+func (sqliteDialect) IndexHint(index ForceIndex) string {
+	if index == "" {
+		return ""
+	}
+	return "INDEXED BY " + string(index)
+}
+
+func (sqliteDialect) Features() Features {
+	return Features{NoDefaultValues: true, Functions: map[string]string{"GREATEST": "max", "LEAST": "min"}}
+}
+
+func (sqliteDialect) RenderOperator(op Operation, field string, operands []string) (string, bool, error) {
+	distinct, ok := _nullSafe(op)
+	switch {
+	case !ok:
+		return "", false, nil
+	case distinct:
+		return field + " is not " + operands[0], true, nil
+	}
+	return field + " is " + operands[0], true, nil
+}
+
+func (sqliteDialect) InsertIgnore() (string, string, error) {
+	return "OR IGNORE", "", nil
+}
+
+// Upsert renders ON CONFLICT, sqlite 3.35 or later accepts a DO UPDATE
+// without a conflict target.
+func (d sqliteDialect) Upsert(c *ConflictClause) (string, error) {
+	if c.Constraint != "" {
+		return "", _wrapError(ErrUnsupported, "sqlite does not support a constraint as conflict target")
+	}
+	return _onConflict(d, c)
+}
+
+func (sqliteDialect) ExcludedColumn(column string) string {
+	return "excluded." + column
+}
+
+type sqlserverDialect struct{}
+
+func (sqlserverDialect) Name() string {
+	return "sqlserver"
+}
+
+func (sqlserverDialect) Placeholder(index int) string {
+	return "@p" + strconv.Itoa(index)
+}
+
+func (sqlserverDialect) QuoteIdent(ident string) string {
+	return _quoteIdent(ident, "[", "]")
+}
+
+// LimitOffset renders OFFSET ... FETCH, which sql server only accepts after
+// an ORDER BY, see Features.LimitNeedsOrder.
+func (sqlserverDialect) LimitOffset(limit Limit, offset Offset) string {
+	if limit == -1 && offset == -1 {
+		return ""
+	}
+	if offset == -1 {
+		offset = 0
+	}
+	res := "OFFSET " + strconv.FormatInt(int64(offset), 10) + " ROWS"
+	if limit != -1 {
+		res += " FETCH NEXT " + strconv.FormatInt(int64(limit), 10) + " ROWS ONLY"
+	}
+	return res
+}
+
+func (sqlserverDialect) Lock(mode LockMode) (string, error) {
+	// sql server locks through table hints such as WITH (UPDLOCK)
+	return "", _wrapError(ErrUnsupported, "sqlserver does not support "+string(mode))
+}
+
+func (sqlserverDialect) IndexHint(index ForceIndex) string {
+	if index == "" {
+		return ""
+	}
+	return "WITH (INDEX(" + string(index) + "))"
+}
+
+func (sqlserverDialect) Features() Features {
+	return Features{ImplicitRecursiveWith: true, LimitNeedsOrder: true, NoRangeOffsets: true}
+}
+
+func (sqlserverDialect) RenderOperator(op Operation, field string, operands []string) (string, bool, error) {
+	if op == OpRegexp {
+		return "", true, _wrapError(ErrUnsupported, "sqlserver does not support "+string(OpRegexp))
+	}
+	return "", false, nil
+}
+
+func (sqlserverDialect) InsertIgnore() (string, string, error) {
+	return "", "", _wrapError(ErrUnsupported, "sqlserver does not support insert ignore")
+}
+
+func (sqlserverDialect) Upsert(c *ConflictClause) (string, error) {
+	return "", _wrapError(ErrUnsupported, "sqlserver does not support upsert")
+}
+
+func _limitOffset(limit Limit, offset Offset) string {
+	return strings.TrimSpace(limit.String() + " " + offset.String())
+}
+
+// _onConflict renders the ON CONFLICT clause of postgres and sqlite.
+func _onConflict(d Dialect, c *ConflictClause) (string, error) {
+	target := ""
+	switch {
+	case c.Constraint != "":
+		target = "ON CONSTRAINT " + d.QuoteIdent(c.Constraint)
+	case len(c.Columns) > 0:
+		cols := make([]string, 0, len(c.Columns))
+		for _, col := range c.Columns {
+			cols = append(cols, d.QuoteIdent(col))
+		}
+		target = _wrapBracket(strings.Join(cols, ","))
+	}
+	if c.DoNothing {
+		return _joinString([]string{"ON CONFLICT", target, "DO NOTHING"}, " "), nil
+	}
+	sets, err := _excludedAssignments(d, c)
+	if err != nil {
+		return "", err
+	}
+	where := ""
+	if c.Where != "" {
+		where = "WHERE " + c.Where
+	}
+	return _joinString([]string{"ON CONFLICT", target, "DO UPDATE SET", sets, where}, " "), nil
+}
+
+// _duplicateKeyAssignments renders the assignments of ON DUPLICATE KEY
+// UPDATE, mysql skips a row by assigning a column to itself.
+func _duplicateKeyAssignments(d Dialect, c *ConflictClause) (string, error) {
+	if c.Where != "" {
+		return "", _wrapError(ErrUnsupported, d.Name()+" does not support a condition on the upsert")
+	}
+	if c.DoNothing {
+		if len(c.Columns) == 0 {
+			return "", _wrapError(ErrUnsupported, d.Name()+" needs a conflict target column to do nothing, or use InsertIgnore")
+		}
+		quoted := d.QuoteIdent(c.Columns[0])
+		return quoted + " = " + quoted, nil
+	}
+	return _excludedAssignments(d, c)
+}
+
+// _excludedAssignments renders `col = <proposed col>` for every column to
+// update, followed by the other assignments.
+func _excludedAssignments(d Dialect, c *ConflictClause) (string, error) {
+	sets := make([]string, 0, len(c.Update)+len(c.Set))
+	if len(c.Update) > 0 {
+		ref, ok := d.(ExcludedDialect)
+		if !ok {
+			return "", _wrapError(ErrUnsupported, d.Name()+" can not refer to the inserted values")
+		}
+		for _, col := range c.Update {
+			quoted := d.QuoteIdent(col)
+			sets = append(sets, quoted+" = "+ref.ExcludedColumn(quoted))
+		}
+	}
+	return strings.Join(append(sets, c.Set...), ", "), nil
+}
+
+// _quoteIdent wraps ident with the given delimiters, doubling any closing
+// delimiter found inside it.
+func _quoteIdent(ident, open, close string) string {
+	return open + strings.ReplaceAll(ident, close, close+close) + close
+}
@@ -0,0 +1,255 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type testDialect struct {
+	mysqlDialect
+}
+
+func (testDialect) Name() string {
+	return "test"
+}
+
+func (testDialect) Placeholder(index int) string {
+	return fmt.Sprintf(":%d", index)
+}
+
+func TestDialects(t *testing.T) {
+	cases := []struct {
+		dialect Dialect
+		sel     string
+		insert  string
+		ignore  string
+	}{
+		{
+			dialect: MySQL,
+			sel:     "SELECT `a` FROM `test` FORCE INDEX(`idx_a`) WHERE `a` = ? ORDER BY `a` ASC LIMIT 10 OFFSET 20",
+			insert:  "INSERT INTO `test` (`a`) VALUES (?) ON DUPLICATE KEY UPDATE `a` = VALUES(`a`)",
+			ignore:  "INSERT IGNORE INTO `test` (`a`) VALUES (?)",
+		},
+		{
+			dialect: MySQL8,
+			sel:     "SELECT `a` FROM `test` FORCE INDEX(`idx_a`) WHERE `a` = ? ORDER BY `a` ASC LIMIT 10 OFFSET 20",
+			insert:  "INSERT INTO `test` (`a`) VALUES (?) AS new ON DUPLICATE KEY UPDATE `a` = new.`a`",
+			ignore:  "INSERT IGNORE INTO `test` (`a`) VALUES (?)",
+		},
+		{
+			dialect: PostgreSQL,
+			sel:     `SELECT "a" FROM "test" WHERE "a" = $1 ORDER BY "a" ASC LIMIT 10 OFFSET 20`,
+			ignore:  `INSERT INTO "test" ("a") VALUES ($1) ON CONFLICT DO NOTHING`,
+		},
+		{
+			dialect: SQLite,
+			sel:     `SELECT "a" FROM "test" INDEXED BY "idx_a" WHERE "a" = ? ORDER BY "a" ASC LIMIT 10 OFFSET 20`,
+			insert:  `INSERT INTO "test" ("a") VALUES (?) ON CONFLICT DO UPDATE SET "a" = excluded."a"`,
+			ignore:  `INSERT OR IGNORE INTO "test" ("a") VALUES (?)`,
+		},
+		{
+			dialect: SQLServer,
+			sel:     "SELECT [a] FROM [test] WITH (INDEX([idx_a])) WHERE [a] = @p1 ORDER BY [a] ASC OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY",
+		},
+		{
+			dialect: testDialect{},
+			sel:     "SELECT `a` FROM `test` FORCE INDEX(`idx_a`) WHERE `a` = :1 ORDER BY `a` ASC LIMIT 10 OFFSET 20",
+			insert:  "INSERT INTO `test` (`a`) VALUES (:1) ON DUPLICATE KEY UPDATE `a` = VALUES(`a`)",
+			ignore:  "INSERT IGNORE INTO `test` (`a`) VALUES (:1)",
+		},
+	}
+
+	for _, c := range cases {
+		opt := NewBuilderOpt{Parameterized: true, Dialect: c.dialect}
+
+		sql, _, err := New("test", opt).Select("a").ForceIndex("idx_a").
+			Where("a", "=", 1).OrderBy("a", OrderAsc).Limit(10).Offset(20).Build()
+		if err != nil || sql != c.sel {
+			t.Errorf("[%s select] wrong sql result: %s %v", c.dialect.Name(), sql, err)
+		}
+
+		sql, _, err = New("test", opt).Insert(map[string]interface{}{"a": 1}).
+			OnDuplicateUpdateKeys("a").Build()
+		if c.insert == "" {
+			if err == nil {
+				t.Errorf("[%s upsert] expected an error, got %s", c.dialect.Name(), sql)
+			}
+		} else if err != nil || sql != c.insert {
+			t.Errorf("[%s upsert] wrong sql result: %s %v", c.dialect.Name(), sql, err)
+		}
+
+		sql, _, err = New("test", opt).InsertIgnore(map[string]interface{}{"a": 1}).Build()
+		if c.ignore == "" {
+			if err == nil {
+				t.Errorf("[%s insert ignore] expected an error, got %s", c.dialect.Name(), sql)
+			}
+		} else if err != nil || sql != c.ignore {
+			t.Errorf("[%s insert ignore] wrong sql result: %s %v", c.dialect.Name(), sql, err)
+		}
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	cases := []struct {
+		dialect Dialect
+		update  string
+		nothing string
+	}{
+		{
+			dialect: MySQL,
+			nothing: "INSERT INTO `test` (`id`,`n`) VALUES (?,?) ON DUPLICATE KEY UPDATE `id` = `id`",
+		},
+		{
+			dialect: MySQL8,
+			nothing: "INSERT INTO `test` (`id`,`n`) VALUES (?,?) AS new ON DUPLICATE KEY UPDATE `id` = `id`",
+		},
+		{
+			dialect: PostgreSQL,
+			update: `INSERT INTO "test" ("id","n") VALUES ($1,$2) ON CONFLICT ("id") DO UPDATE SET "name" = excluded."name", ` +
+				`"at" = $3, "n" = "test"."n" + excluded."n" WHERE "test"."n" < $4`,
+			nothing: `INSERT INTO "test" ("id","n") VALUES ($1,$2) ON CONFLICT ("id") DO NOTHING`,
+		},
+		{
+			dialect: SQLite,
+			update: `INSERT INTO "test" ("id","n") VALUES (?,?) ON CONFLICT ("id") DO UPDATE SET "name" = excluded."name", ` +
+				`"at" = ?, "n" = "test"."n" + excluded."n" WHERE "test"."n" < ?`,
+			nothing: `INSERT INTO "test" ("id","n") VALUES (?,?) ON CONFLICT ("id") DO NOTHING`,
+		},
+		{
+			dialect: SQLServer,
+		},
+	}
+
+	for _, c := range cases {
+		opt := NewBuilderOpt{Parameterized: true, Dialect: c.dialect}
+		row := map[string]interface{}{"id": 1, "n": 2}
+
+		sql, args, err := New("test", opt).Insert(row).OnConflict("id").DoUpdate("name", "n").
+			DoUpdateSet(map[string]interface{}{"n": Col("test.n").Add(Excluded("n")), "at": 3}).
+			DoUpdateWhere(func(w Wheres) Wheres {
+				return w.Where("test.n", "<", 4)
+			}).Build()
+		if c.update == "" {
+			if err == nil {
+				t.Errorf("[%s upsert] expected an error, got %s", c.dialect.Name(), sql)
+			}
+		} else if err != nil || sql != c.update || fmt.Sprint(args) != "[1 2 3 4]" {
+			t.Errorf("[%s upsert] wrong sql result: %s %v %v", c.dialect.Name(), sql, args, err)
+		}
+
+		sql, _, err = New("test", opt).Insert(row).OnConflict("id").DoNothing().Build()
+		if c.nothing == "" {
+			if err == nil {
+				t.Errorf("[%s do nothing] expected an error, got %s", c.dialect.Name(), sql)
+			}
+		} else if err != nil || sql != c.nothing {
+			t.Errorf("[%s do nothing] wrong sql result: %s %v", c.dialect.Name(), sql, err)
+		}
+	}
+
+	sql, _, err := New("test", NewBuilderOpt{Parameterized: true, Dialect: MySQL8}).Insert(map[string]interface{}{"id": 1, "n": 2}).
+		DoUpdateSet(map[string]interface{}{"n": Col("n").Add(Excluded("n"))}).Build()
+	if err != nil || sql != "INSERT INTO `test` (`id`,`n`) VALUES (?,?) AS new ON DUPLICATE KEY UPDATE `n` = `n` + new.`n`" {
+		t.Error("[mysql8 upsert] wrong sql result", sql, err)
+	}
+
+	sql, _, err = New("test", NewBuilderOpt{Parameterized: true, Dialect: PostgreSQL}).Insert(map[string]interface{}{"id": 1}).
+		OnConflictConstraint("test_pkey").DoUpdate("id").Build()
+	if err != nil || sql != `INSERT INTO "test" ("id") VALUES ($1) ON CONFLICT ON CONSTRAINT "test_pkey" DO UPDATE SET "id" = excluded."id"` {
+		t.Error("[constraint upsert] wrong sql result", sql, err)
+	}
+
+	if _, _, err = New("test").Insert(map[string]interface{}{"id": 1}).OnConflict("id").Build(); !errors.Is(err, ErrInvalidUpsert) {
+		t.Error("[upsert] expected ErrInvalidUpsert without an action, got", err)
+	}
+
+	pg := NewBuilderOpt{Parameterized: true, Dialect: PostgreSQL}
+	_, _, err = New("test", pg).InsertIgnore(map[string]interface{}{"id": 1}).OnConflict("id").DoUpdate("n").Build()
+	if !errors.Is(err, ErrInvalidUpsert) {
+		t.Error("[upsert] expected ErrInvalidUpsert for an insert ignore, got", err)
+	}
+	sql, _, err = New("test", NewBuilderOpt{Parameterized: true}).InsertIgnore(map[string]interface{}{"id": 1, "n": 2}).
+		OnDuplicateUpdateKeys("n").Build()
+	if err != nil || sql != "INSERT IGNORE INTO `test` (`id`,`n`) VALUES (?,?) ON DUPLICATE KEY UPDATE `n` = VALUES(`n`)" {
+		t.Error("[mysql upsert] wrong sql result for an insert ignore", sql, err)
+	}
+
+	if _, _, err = New("test", pg).Select("a").Where("b", "=", Excluded("n")).Query().Build(); !errors.Is(err, ErrInvalidExpression) {
+		t.Error("[excluded] expected ErrInvalidExpression outside of an upsert, got", err)
+	}
+
+	sql, _, err = New("test", NewBuilderOpt{Parameterized: true, Dialect: aliasDialect{PostgreSQL}}).
+		Insert(map[string]interface{}{"id": 1, "n": 2}).
+		OnConflict("id").DoUpdateSet(map[string]interface{}{"n": Excluded("n")}).Build()
+	if err != nil || sql != `INSERT INTO "test" ("id","n") VALUES ($1,$2) ON CONFLICT ("id") DO UPDATE SET "n" = src."n"` {
+		t.Error("[custom dialect upsert] wrong sql result", sql, err)
+	}
+}
+
+// aliasDialect is a dialect of another package, referring to the inserted
+// values through an alias.
+type aliasDialect struct {
+	Dialect
+}
+
+func (aliasDialect) ExcludedColumn(column string) string {
+	return "src." + column
+}
+
+func TestDialectFeatures(t *testing.T) {
+	// a dialect built on mysql renders like mysql, whatever its name
+	sql, args, err := New("test", NewBuilderOpt{Dialect: testDialect{}}).Insert(map[string]interface{}{"s": "o'x"}).Build()
+	if err != nil || sql != "INSERT INTO `test` (`s`) VALUES (\"%s\")" || fmt.Sprint(args) != `[o\'x]` {
+		t.Error("[literal] wrong sql result", sql, args, err)
+	}
+	sql, _, err = New("test", NewBuilderOpt{Parameterized: true, Dialect: testDialect{}}).Select("a").
+		Where("a", OpIsDistinctFrom, 1).WhereLike("b", "x%").Query().Build()
+	if err != nil || sql != "SELECT `a` FROM `test` WHERE not (`a` <=> :1) AND `b` like :2" {
+		t.Error("[operators] wrong sql result", sql, err)
+	}
+	if _, _, err = New("test", NewBuilderOpt{Parameterized: true, Dialect: testDialect{}}).
+		Join("b", func(w Wheres) Wheres { return w.On("b.id", "=", "test.id") }).
+		Where("a", "=", 1).Delete().Build(); err != nil {
+		t.Error("[write joins] unexpected error", err)
+	}
+
+	// a dialect without features renders standard sql
+	sql, _, err = New("test", NewBuilderOpt{Parameterized: true, Dialect: aliasDialect{PostgreSQL}}).Select("a").
+		Where("a", OpIsDistinctFrom, 1).WhereLike("b", "x%").Query().Build()
+	if err != nil || sql != `SELECT "a" FROM "test" WHERE "a" is distinct from $1 AND "b" like $2 escape '\'` {
+		t.Error("[standard] wrong sql result", sql, err)
+	}
+}
+
+func TestDialectLimitOffset(t *testing.T) {
+	cases := []struct {
+		dialect Dialect
+		limit   Limit
+		offset  Offset
+		want    string
+	}{
+		{MySQL, 10, -1, "LIMIT 10"},
+		{PostgreSQL, -1, 5, "OFFSET 5"},
+		{SQLite, -1, 5, "LIMIT -1 OFFSET 5"},
+		{SQLServer, -1, -1, ""},
+		{SQLServer, 10, -1, "OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY"},
+		{SQLServer, -1, 5, "OFFSET 5 ROWS"},
+	}
+	for _, c := range cases {
+		if got := c.dialect.LimitOffset(c.limit, c.offset); got != c.want {
+			t.Errorf("[%s] LimitOffset(%d, %d) = %q, want %q", c.dialect.Name(), c.limit, c.offset, got, c.want)
+		}
+	}
+}
+
+func TestRegisterDialect(t *testing.T) {
+	RegisterDialect(testDialect{})
+	d, ok := GetDialect("test")
+	if !ok || d.Placeholder(3) != ":3" {
+		t.Error("dialect not registered")
+	}
+	if d, ok := GetDialect("postgres"); !ok || d != PostgreSQL {
+		t.Error("builtin dialect not registered")
+	}
+}
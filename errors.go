@@ -0,0 +1,65 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors returned by Build and Validate, test them with errors.Is as most
+// of them are wrapped with the details of the problem.
+var (
+	ErrEmptyTable               = errors.New("invalid sql: empty table name")
+	ErrUnknownAction            = errors.New("invalid sql: wrong action")
+	ErrNoValues                 = errors.New("invalid sql: no values to write")
+	ErrNoWhere                  = errors.New("invalid sql: can not update or delete without where conditions")
+	ErrInconsistentBatchColumns = errors.New("invalid sql: batch rows have different columns")
+	ErrUnknownOperator          = errors.New("invalid sql: unknown operator")
+	ErrInvalidIdentifier        = errors.New("invalid sql: invalid identifier")
+	ErrInvalidExpression        = errors.New("invalid sql: invalid expression")
+	ErrUnsupported              = errors.New("invalid sql: not supported by the dialect")
+	ErrNotParameterized         = errors.New("invalid sql: only a parameterized builder can be executed")
+	ErrReleased                 = errors.New("invalid sql: builder used after Release")
+	ErrMultipleStatements       = errors.New("invalid sql: the batch needs several statements, use BuildAll")
+	ErrInvalidUpsert            = errors.New("invalid sql: invalid upsert")
+	ErrNotStruct                = errors.New("invalid sql: the value is not a struct")
+	ErrInvalidScanDest          = errors.New("invalid scan destination")
+)
+
+// _wrapError adds the details in msg to the sentinel err.
+func _wrapError(err error, msg string) error {
+	return fmt.Errorf("%w: %s", err, msg)
+}
+
+// ValidationError holds every problem found by Validate.
+type ValidationError struct {
+	Errs []error
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the problems matches target.
+func (e *ValidationError) Is(target error) bool {
+	for _, err := range e.Errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first problem matching target.
+func (e *ValidationError) As(target interface{}) bool {
+	for _, err := range e.Errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package builder
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	cases := map[string]struct {
+		builder *SQLBulder
+		want    error
+	}{
+		"empty table":  {New("").Select("a").Query(), ErrEmptyTable},
+		"no action":    {New("test"), ErrUnknownAction},
+		"no values":    {New("test").Insert(nil), ErrNoValues},
+		"no where":     {New("test").Update(map[string]interface{}{"a": 1}), ErrNoWhere},
+		"delete":       {New("test").Delete(), ErrNoWhere},
+		"operator":     {New("test").Select("a").Where("a", "~~", 1).Query(), ErrUnknownOperator},
+		"identifier":   {New("test").Select("a b").Query(), ErrInvalidIdentifier},
+		"expression":   {New("test").SelectExpr(NewExpr("?")).Query(), ErrInvalidExpression},
+		"not support":  {New("test", NewBuilderOpt{Dialect: SQLite}).Select("a").ForUpdate().Query(), ErrUnsupported},
+		"batch insert": {New("test").BatchInsert([]map[string]interface{}{{"a": 1}, {"b": 2}}), ErrInconsistentBatchColumns},
+		"order":        {New("test").Select("a").OrderBy("a", OrderEnum("sideways")).Query(), ErrInvalidExpression},
+		"struct":       {New("test").InsertStruct(1), ErrNotStruct},
+	}
+	for name, c := range cases {
+		if err := c.builder.Validate(); !errors.Is(err, c.want) {
+			t.Errorf("[%s validate] got %v, want %v", name, err, c.want)
+		}
+		if _, _, err := c.builder.Build(); !errors.Is(err, c.want) {
+			t.Errorf("[%s build] got %v, want %v", name, err, c.want)
+		}
+	}
+
+	var opErr *OperatorError
+	_, _, err := New("test").Select("a").Where("a", "~~", 1).Query().Build()
+	if !errors.As(err, &opErr) || !errors.Is(err, ErrUnknownOperator) {
+		t.Error("expected an operator error", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	b := New("test t", NewBuilderOpt{Parameterized: true, Dialect: PostgreSQL}).
+		Select("a", "b;").
+		Join("x y z", func(w Wheres) Wheres { return w.On("y.id", "=", "t.id") }).
+		Where("c", "~~", 1).
+		Wheres(func(w Wheres) Wheres { return w.Where("d)", "=", 2) }).
+		OrderBy("e", OrderEnum("sideways")).
+		Query()
+	err := b.Validate()
+	var vErr *ValidationError
+	if !errors.As(err, &vErr) || len(vErr.Errs) != 5 {
+		t.Fatal("expected 5 problems, got", err)
+	}
+	if !errors.Is(err, ErrInvalidIdentifier) || !errors.Is(err, ErrUnknownOperator) || errors.Is(err, ErrNoWhere) {
+		t.Error("wrong problems", err)
+	}
+	var opErr *OperatorError
+	if !errors.As(err, &opErr) || opErr.Operation != "~~" {
+		t.Error("expected the operator error", err)
+	}
+
+	ok := New("test", NewBuilderOpt{Parameterized: true}).Select("a").Where("a", "=", 1).Query()
+	if err := ok.Validate(); err != nil {
+		t.Error("unexpected problems", err)
+	}
+	if sql, _, err := ok.Build(); err != nil || sql != "SELECT `a` FROM `test` WHERE `a` = ?" {
+		t.Error("wrong sql result after validate", sql, err)
+	}
+}
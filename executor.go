@@ -0,0 +1,129 @@
+package builder
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Executor runs statements, *sql.DB, *sql.Tx and *sql.Conn all satisfy it.
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// statement builds the statement to run, only parameterized builders can
+// be run since the others inline their values into format verbs.
+func (builder *SQLBulder) statement() (string, []interface{}, error) {
+	if err := builder.checkReleased(); err != nil {
+		return "", nil, err
+	}
+	if !builder.parameterized {
+		return "", nil, ErrNotParameterized
+	}
+	return builder.Build()
+}
+
+// TxBeginner starts transactions, *sql.DB and *sql.Conn satisfy it.
+type TxBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// ExecAllContext runs every statement of BuildAll, such as the chunks of a
+// large batch insert, in a transaction begun on db. The transaction is
+// rolled back when a statement fails, otherwise the rows affected by all
+// statements are returned.
+func (builder *SQLBulder) ExecAllContext(ctx context.Context, db TxBeginner) (int64, error) {
+	if err := builder.checkReleased(); err != nil {
+		return 0, err
+	}
+	if !builder.parameterized {
+		return 0, ErrNotParameterized
+	}
+	stmts, err := builder.BuildAll()
+	if err != nil {
+		return 0, err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	var affected int64
+	for _, s := range stmts {
+		res, err := tx.ExecContext(ctx, s.SQL, s.Args...)
+		if err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+		affected += n
+	}
+	return affected, tx.Commit()
+}
+
+// ExecContext runs an insert, update or delete, the result carries
+// LastInsertId and RowsAffected.
+func (builder *SQLBulder) ExecContext(ctx context.Context, e Executor) (sql.Result, error) {
+	query, args, err := builder.statement()
+	if err != nil {
+		return nil, err
+	}
+	return e.ExecContext(ctx, query, args...)
+}
+
+func (builder *SQLBulder) QueryContext(ctx context.Context, e Executor) (*sql.Rows, error) {
+	query, args, err := builder.statement()
+	if err != nil {
+		return nil, err
+	}
+	return e.QueryContext(ctx, query, args...)
+}
+
+func (builder *SQLBulder) QueryRowContext(ctx context.Context, e Executor) (*sql.Row, error) {
+	query, args, err := builder.statement()
+	if err != nil {
+		return nil, err
+	}
+	return e.QueryRowContext(ctx, query, args...), nil
+}
+
+// GetContext scans the first row into dest, a pointer to a struct, a
+// map[string]interface{} or a value of a single column, see ScanOne. It
+// returns sql.ErrNoRows when there is no row.
+func (builder *SQLBulder) GetContext(ctx context.Context, e Executor, dest interface{}) error {
+	rows, err := builder.QueryContext(ctx, e)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if err := ScanOne(rows, dest); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// SelectContext scans every row into dest, a pointer to a slice of structs,
+// pointers to structs, maps or values of a single column, see ScanAll.
+func (builder *SQLBulder) SelectContext(ctx context.Context, e Executor, dest interface{}) error {
+	rows, err := builder.QueryContext(ctx, e)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if err := ScanAll(rows, dest); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// PluckContext selects the single column into dest, a pointer to a slice of
+// its values.
+func (builder *SQLBulder) PluckContext(ctx context.Context, e Executor, column string, dest interface{}) error {
+	builder = builder.derive()
+	builder.fields = nil
+	return builder.Select(column).SelectContext(ctx, e, dest)
+}
@@ -0,0 +1,224 @@
+package builder
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+// fakeDB records the statements run through the fake driver and answers
+// queries with canned rows.
+type fakeDB struct {
+	mu       sync.Mutex
+	queries  []string
+	args     [][]interface{}
+	columns  []string
+	rows     [][]driver.Value
+	commits  int
+	rollback int
+	// failAt fails the exec with this 1-based index, 0 never fails
+	failAt int
+	execs  int
+}
+
+func (db *fakeDB) record(query string, args []driver.NamedValue) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	values := make([]interface{}, 0, len(args))
+	for _, arg := range args {
+		values = append(values, arg.Value)
+	}
+	db.queries = append(db.queries, query)
+	db.args = append(db.args, values)
+}
+
+var fakeDBs sync.Map
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	db, ok := fakeDBs.Load(name)
+	if !ok {
+		return nil, errors.New("unknown fake db " + name)
+	}
+	return &fakeConn{db: db.(*fakeDB)}, nil
+}
+
+func init() {
+	sql.Register("sqlbuilder_fake", fakeDriver{})
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare is not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return &fakeTx{db: c.db}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.record(query, args)
+	c.db.execs++
+	if c.db.execs == c.db.failAt {
+		return nil, errors.New("exec failed")
+	}
+	return driver.RowsAffected(int64(len(args))), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.record(query, args)
+	return &fakeRows{columns: c.db.columns, rows: c.db.rows}, nil
+}
+
+type fakeTx struct {
+	db *fakeDB
+}
+
+func (tx *fakeTx) Commit() error {
+	tx.db.commits++
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.db.rollback++
+	return nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	i       int
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.i])
+	r.i++
+	return nil
+}
+
+func openFakeDB(t *testing.T, columns []string, rows ...[]driver.Value) (*sql.DB, *fakeDB) {
+	fake := &fakeDB{columns: columns, rows: rows}
+	fakeDBs.Store(t.Name(), fake)
+	db, err := sql.Open("sqlbuilder_fake", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		fakeDBs.Delete(t.Name())
+	})
+	return db, fake
+}
+
+func TestExecutor(t *testing.T) {
+	ctx := context.Background()
+	opt := NewBuilderOpt{Parameterized: true, Reuse: true}
+	db, fake := openFakeDB(t, []string{"id"}, []driver.Value{int64(1)}, []driver.Value{int64(2)})
+
+	res, err := New("users", opt).Where("id", "=", 3).Update(map[string]interface{}{"name": "jack"}).ExecContext(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 2 {
+		t.Error("[exec] wrong rows affected", n)
+	}
+	if fake.queries[0] != "UPDATE `users` SET `name` = ? WHERE `id` = ?" || fmt.Sprint(fake.args[0]) != "[jack 3]" {
+		t.Error("[exec] wrong statement", fake.queries[0], fake.args[0])
+	}
+
+	var id int64
+	if err := New("users", opt).Select("id").Where("name", "=", "jack").GetContext(ctx, db, &id); err != nil || id != 1 {
+		t.Error("[get] wrong result", id, err)
+	}
+
+	var ids []int64
+	if err := New("users", opt).Select("id").Query().SelectContext(ctx, db, &ids); err != nil || fmt.Sprint(ids) != "[1 2]" {
+		t.Error("[select] wrong result", ids, err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, err := New("users", opt).Select("id").Query().QueryRowContext(ctx, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := row.Scan(&id); err != nil || id != 1 {
+		t.Error("[query row] wrong result", id, err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New("").Select("id").Query().ExecContext(ctx, db); err == nil {
+		t.Error("[exec] expected an error for a builder without table")
+	}
+	if _, err := New("users", NewBuilderOpt{}).Delete().Where("id", "=", 1).ExecContext(ctx, db); err == nil {
+		t.Error("[exec] expected an error for a non parameterized builder")
+	}
+}
+
+func TestExecutorNoRows(t *testing.T) {
+	db, _ := openFakeDB(t, []string{"id"})
+
+	var id int64
+	err := New("users").Select("id").Query().GetContext(context.Background(), db, &id)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Error("[get] expected sql.ErrNoRows, got", err)
+	}
+}
+
+func TestExecAll(t *testing.T) {
+	db, fake := openFakeDB(t, nil)
+	ctx := context.Background()
+
+	rows := make([]map[string]interface{}, 0, 5)
+	for i := 0; i < 5; i++ {
+		rows = append(rows, map[string]interface{}{"a": i, "b": i * 10})
+	}
+	opt := NewBuilderOpt{Parameterized: true, Dialect: PostgreSQL}
+	n, err := New("test", opt).BatchInsert(rows).Chunk(ChunkLimits{MaxPlaceholders: 4}).ExecAllContext(ctx, db)
+	if err != nil || n != 10 {
+		t.Fatal("[exec all] wrong result", n, err)
+	}
+	if len(fake.queries) != 3 || fake.queries[0] != `INSERT INTO "test" ("a","b") VALUES ($1,$2),($3,$4)` ||
+		fake.queries[2] != `INSERT INTO "test" ("a","b") VALUES ($1,$2)` || fmt.Sprint(fake.args[1]) != "[2 20 3 30]" {
+		t.Error("[exec all] wrong statements", fake.queries, fake.args)
+	}
+	if fake.commits != 1 || fake.rollback != 0 {
+		t.Error("[exec all] expected a commit", fake.commits, fake.rollback)
+	}
+
+	fake.failAt = fake.execs + 2
+	if _, err = New("test", opt).BatchInsert(rows).Chunk(ChunkLimits{MaxRows: 2}).ExecAllContext(ctx, db); err == nil {
+		t.Error("[exec all] expected the exec error")
+	}
+	if fake.commits != 1 || fake.rollback != 1 {
+		t.Error("[exec all] expected a rollback", fake.commits, fake.rollback)
+	}
+}
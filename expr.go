@@ -0,0 +1,154 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Expr is an sql expression bound to Args by ? placeholders, which are
+// rewritten to the placeholders of the dialect when the statement is built.
+// A ? inside a quoted literal or identifier is left alone and ?? renders a
+// literal ?, like the jsonb operators of postgres. An arg can be a Column,
+// which is quoted in place of its placeholder.
+type Expr struct {
+	SQL  string
+	Args []interface{}
+	// fn is the function called with SQL as its arguments, its name can
+	// differ per dialect.
+	fn string
+}
+
+func NewExpr(sql string, args ...interface{}) Expr {
+	return Expr{SQL: sql, Args: args}
+}
+
+// bind renders e, numbering its placeholders after the ones already
+// rendered in st.
+func (st *stmt) bind(e Expr) (string, []interface{}, error) {
+	sb := strings.Builder{}
+	args := make([]interface{}, 0, len(e.Args))
+	i := 0
+	var quote byte
+	for pos := 0; pos < len(e.SQL); pos++ {
+		c := e.SQL[pos]
+		switch {
+		case quote != 0:
+			// mysql escapes quotes inside literals with a backslash as well
+			if c == '\\' && quote == '\'' && _features(st.dialect).BackslashEscapes && pos+1 < len(e.SQL) {
+				sb.WriteByte(c)
+				pos++
+				c = e.SQL[pos]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?' && pos+1 < len(e.SQL) && e.SQL[pos+1] == '?':
+			pos++
+		case c == '?':
+			if i == len(e.Args) {
+				return "", nil, _exprError(e)
+			}
+			operands, operandArgs, err := st.operands(e.Args[i : i+1])
+			if err != nil {
+				return "", nil, err
+			}
+			sb.WriteString(operands[0])
+			args = append(args, operandArgs...)
+			i++
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	if i != len(e.Args) {
+		return "", nil, _exprError(e)
+	}
+	if e.fn != "" {
+		return _funcName(st.dialect, e.fn) + _wrapBracket(sb.String()), args, nil
+	}
+	return sb.String(), args, nil
+}
+
+func _exprError(e Expr) error {
+	return _wrapError(ErrInvalidExpression, fmt.Sprintf("%q does not have a placeholder for each of its %d args", e.SQL, len(e.Args)))
+}
+
+// Add renders c + v, v can be a value, a Column or an Expr.
+func (c Column) Add(v interface{}) Expr {
+	return _arithmetic(c, "+", v)
+}
+
+// Sub renders c - v.
+func (c Column) Sub(v interface{}) Expr {
+	return _arithmetic(c, "-", v)
+}
+
+// Mul renders c * v.
+func (c Column) Mul(v interface{}) Expr {
+	return _arithmetic(c, "*", v)
+}
+
+// Div renders c / v.
+func (c Column) Div(v interface{}) Expr {
+	return _arithmetic(c, "/", v)
+}
+
+// Add renders (e) + v.
+func (e Expr) Add(v interface{}) Expr {
+	return _arithmetic(e, "+", v)
+}
+
+// Sub renders (e) - v.
+func (e Expr) Sub(v interface{}) Expr {
+	return _arithmetic(e, "-", v)
+}
+
+// Mul renders (e) * v.
+func (e Expr) Mul(v interface{}) Expr {
+	return _arithmetic(e, "*", v)
+}
+
+// Div renders (e) / v.
+func (e Expr) Div(v interface{}) Expr {
+	return _arithmetic(e, "/", v)
+}
+
+// Greatest renders the largest of the values, with max() on sqlite.
+func Greatest(values ...interface{}) Expr {
+	return _call("GREATEST", values)
+}
+
+// Least renders the smallest of the values, with min() on sqlite.
+func Least(values ...interface{}) Expr {
+	return _call("LEAST", values)
+}
+
+// Coalesce renders the first of the values which is not null.
+func Coalesce(values ...interface{}) Expr {
+	return _call("COALESCE", values)
+}
+
+// _arithmetic binds both operands to placeholders, an Expr operand is
+// bracketed to keep its precedence.
+func _arithmetic(left interface{}, op string, right interface{}) Expr {
+	return NewExpr("? "+op+" ?", _bracketExpr(left), _bracketExpr(right))
+}
+
+func _bracketExpr(v interface{}) interface{} {
+	if e, ok := v.(Expr); ok && e.fn == "" {
+		return Expr{SQL: _wrapBracket(e.SQL), Args: e.Args}
+	}
+	return v
+}
+
+func _call(fn string, values []interface{}) Expr {
+	return Expr{SQL: strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", "), Args: values, fn: fn}
+}
+
+// _funcName renders the function fn in the dialect d.
+func _funcName(d Dialect, fn string) string {
+	if name, ok := _features(d).Functions[fn]; ok {
+		return name
+	}
+	return fn
+}
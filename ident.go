@@ -0,0 +1,133 @@
+package builder
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Raw is an sql expression rendered verbatim, it skips identifier
+// validation and quoting so it must never carry user input.
+type Raw string
+
+const identPattern = `[A-Za-z_][A-Za-z0-9_$]*`
+
+var (
+	identRe  = regexp.MustCompile(`^` + identPattern + `$`)
+	funcRe   = regexp.MustCompile(`^(` + identPattern + `)\(\s*((?i:distinct)\s+)?(.*?)\s*\)$`)
+	aliasRe  = regexp.MustCompile(`^(.+?)\s+(?i:as)\s+(` + identPattern + `)$`)
+	tableRe  = regexp.MustCompile(`^(\S+)(?:\s+((?i:as)\s+)?(` + identPattern + `))?$`)
+	numberRe = regexp.MustCompile(`^-?[0-9]+(?:\.[0-9]+)?$`)
+)
+
+func _identError(ident string) error {
+	return fmt.Errorf("%w %q", ErrInvalidIdentifier, ident)
+}
+
+// quoteIdent validates and quotes a single identifier.
+func quoteIdent(d Dialect, ident string) (string, error) {
+	if !identRe.MatchString(ident) {
+		return "", _identError(ident)
+	}
+	return d.QuoteIdent(ident), nil
+}
+
+// quoteName validates and quotes every part of a qualified name such as
+// db.table.col, the last part can be *.
+func quoteName(d Dialect, name string) (string, error) {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if part == "*" && i == len(parts)-1 {
+			continue
+		}
+		if !identRe.MatchString(part) {
+			return "", _identError(name)
+		}
+		parts[i] = d.QuoteIdent(part)
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// quoteColumn validates and quotes a column expression, which is a name, a
+// number, or a function called with a single name like count(*) or
+// sum(DISTINCT o.amount). With alias set it can be followed by AS alias.
+func quoteColumn(d Dialect, expr string, alias bool) (string, error) {
+	expr = strings.TrimSpace(expr)
+	if alias {
+		if m := aliasRe.FindStringSubmatch(expr); m != nil {
+			column, err := quoteColumn(d, m[1], false)
+			if err != nil {
+				return "", err
+			}
+			return column + " AS " + d.QuoteIdent(m[2]), nil
+		}
+	}
+	if numberRe.MatchString(expr) {
+		return expr, nil
+	}
+	if m := funcRe.FindStringSubmatch(expr); m != nil {
+		if m[3] == "" || m[3] == "*" || numberRe.MatchString(m[3]) {
+			if m[2] != "" {
+				return "", _identError(expr)
+			}
+			return m[1] + _wrapBracket(m[3]), nil
+		}
+		arg, err := quoteName(d, m[3])
+		if err != nil {
+			return "", _identError(expr)
+		}
+		if m[2] != "" {
+			arg = "DISTINCT " + arg
+		}
+		return m[1] + _wrapBracket(arg), nil
+	}
+	column, err := quoteName(d, expr)
+	if err != nil {
+		return "", _identError(expr)
+	}
+	return column, nil
+}
+
+// quoteTable validates and quotes a table reference written as "table",
+// "table alias" or "table AS alias".
+func quoteTable(d Dialect, ref string) (string, error) {
+	m := tableRe.FindStringSubmatch(strings.TrimSpace(ref))
+	if m == nil {
+		return "", _identError(ref)
+	}
+	table, err := quoteName(d, m[1])
+	if err != nil || strings.HasSuffix(m[1], "*") {
+		return "", _identError(ref)
+	}
+	if m[3] == "" {
+		return table, nil
+	}
+	if m[2] != "" {
+		return table + " AS " + d.QuoteIdent(m[3]), nil
+	}
+	return table + " " + d.QuoteIdent(m[3]), nil
+}
+
+// quoteIdents validates, quotes and joins idents with commas.
+func quoteIdents(d Dialect, idents []string) (string, error) {
+	quoted := make([]string, 0, len(idents))
+	for _, ident := range idents {
+		q, err := quoteIdent(d, ident)
+		if err != nil {
+			return "", err
+		}
+		quoted = append(quoted, q)
+	}
+	return strings.Join(quoted, ","), nil
+}
+
+// expr renders a column expression, or a Raw expression verbatim.
+func (st *stmt) expr(v interface{}) (string, error) {
+	switch e := v.(type) {
+	case Raw:
+		return string(e), nil
+	case string:
+		return quoteColumn(st.dialect, e, false)
+	}
+	return "", _wrapError(ErrInvalidExpression, fmt.Sprintf("unsupported type %T", v))
+}
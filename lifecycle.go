@@ -0,0 +1,156 @@
+package builder
+
+// Release returns a builder created with Reuse to the pool. The builder
+// must not be used afterwards, since a pooled builder may already belong to
+// someone else: building, validating or running it reports ErrReleased, and
+// builds tagged sqlbuilder_debug panic on any use, changes included.
+// Building does not release, so a builder can be built again, logged or
+// retried until it is released.
+func (builder *SQLBulder) Release() {
+	if builder.released {
+		if debugLifecycle {
+			panic(ErrReleased)
+		}
+		return
+	}
+	builder.released = true
+	// a released builder is never pooled in debug builds, which keeps it
+	// marked so that every later use is caught
+	if !debugLifecycle {
+		putBackBuilder(builder)
+	}
+}
+
+// checkReleased reports the use of a released builder, debug builds panic.
+func (builder *SQLBulder) checkReleased() error {
+	if !builder.released {
+		return nil
+	}
+	if debugLifecycle {
+		panic(ErrReleased)
+	}
+	return ErrReleased
+}
+
+// Clone returns a deep copy of the builder, conditions, orders, values and
+// subqueries included, which can be changed and released on its own.
+func (builder *SQLBulder) Clone() *SQLBulder {
+	c := newSQLBuilder(builder.tableName, builder.reuse)
+	if err := builder.checkReleased(); err != nil {
+		return c.setErr(err)
+	}
+	c.action = builder.action
+	c.distinct = builder.distinct
+	c.parameterized = builder.parameterized
+	c.dialect = builder.dialect
+	c.allowUnknownOps = builder.allowUnknownOps
+	c.immutable = builder.immutable
+	c.limitSize = builder.limitSize
+	c.offsetSize = builder.offsetSize
+	c.forceIndexName = builder.forceIndexName
+	c.lock = builder.lock
+	c.fields = append(c.fields, builder.fields...)
+	c.args = append(c.args, builder.args...)
+	c.groups = append(c.groups, builder.groups...)
+	c.columns = append([]string(nil), builder.columns...)
+	c.errs = append([]error(nil), builder.errs...)
+	c.whereList = builder.whereList.clone()
+	c.havingList = builder.havingList.clone()
+	for _, o := range builder.orders {
+		order := *o
+		c.orders = append(c.orders, &order)
+	}
+	for _, row := range builder.values {
+		values := make(map[string]interface{}, len(row))
+		for field, value := range row {
+			values[field] = value
+		}
+		c.values = append(c.values, values)
+	}
+	for _, j := range builder.joins {
+		c.joins = append(c.joins, &Join{Type: j.Type, Table: j.Table, On: j.On.clone()})
+	}
+	for _, cp := range builder.compounds {
+		c.compounds = append(c.compounds, &Compound{Op: cp.Op, Query: cp.Query.Clone()})
+	}
+	for _, cte := range builder.ctes {
+		cloned := &CTE{Name: cte.Name, Columns: cte.Columns, Query: cte.Query.Clone()}
+		if cte.Recursive != nil {
+			cloned.Recursive = cte.Recursive.Clone()
+		}
+		c.ctes = append(c.ctes, cloned)
+	}
+	c.windows = append(c.windows, builder.windows...)
+	if builder.fromSub != nil {
+		c.fromSub = builder.fromSub.Clone()
+	}
+	c.insert = &Insert{
+		onDupKeyUpdates: append(onDupKeyUpdates(nil), builder.insert.onDupKeyUpdates...),
+		ignore:          builder.insert.ignore,
+		batchMode:       builder.insert.batchMode,
+		chunk:           builder.insert.chunk,
+		conflict:        builder.insert.conflict.clone(),
+	}
+	return c
+}
+
+// clone copies the conditions, keeping the links between combined
+// conditions within the copy.
+func (whs Wheres) clone() Wheres {
+	cloned := make(Wheres, 0, len(whs))
+	copies := make(map[*Where]*Where, len(whs))
+	for _, wh := range whs {
+		c := *wh
+		c.Value = make([]interface{}, 0, len(wh.Value))
+		for _, v := range wh.Value {
+			if sub, ok := v.(*SQLBulder); ok {
+				v = sub.Clone()
+			}
+			c.Value = append(c.Value, v)
+		}
+		c.Children = wh.Children.clone()
+		copies[wh] = &c
+		cloned = append(cloned, &c)
+	}
+	for _, c := range cloned {
+		if c.CombineLeft != nil {
+			c.CombineLeft = copies[c.CombineLeft]
+		}
+		if c.CombineRight != nil {
+			c.CombineRight = copies[c.CombineRight]
+		}
+	}
+	return cloned
+}
+
+// derive returns the builder a method changes, which is the builder itself
+// unless it is immutable. An immutable builder is copied instead, sharing
+// its state with the copy: the slices are capped to their length so that
+// appending to them reallocates, and nothing shared is changed in place.
+func (builder *SQLBulder) derive() *SQLBulder {
+	// only debug builds catch the change of a released builder, Build
+	// reports it otherwise
+	_ = builder.checkReleased()
+	if !builder.immutable {
+		return builder
+	}
+	c := *builder
+	c.fields = c.fields[:len(c.fields):len(c.fields)]
+	c.args = c.args[:len(c.args):len(c.args)]
+	c.whereList = c.whereList[:len(c.whereList):len(c.whereList)]
+	c.havingList = c.havingList[:len(c.havingList):len(c.havingList)]
+	c.orders = c.orders[:len(c.orders):len(c.orders)]
+	c.groups = c.groups[:len(c.groups):len(c.groups)]
+	c.values = c.values[:len(c.values):len(c.values)]
+	c.columns = c.columns[:len(c.columns):len(c.columns)]
+	c.joins = c.joins[:len(c.joins):len(c.joins)]
+	c.compounds = c.compounds[:len(c.compounds):len(c.compounds)]
+	c.ctes = c.ctes[:len(c.ctes):len(c.ctes)]
+	c.windows = c.windows[:len(c.windows):len(c.windows)]
+	c.errs = c.errs[:len(c.errs):len(c.errs)]
+	insert := *builder.insert
+	c.insert = &insert
+	// only the builder from New goes back to the pool
+	c.reuse = false
+	return &c
+}
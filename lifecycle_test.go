@@ -0,0 +1,206 @@
+package builder
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestRelease(t *testing.T) {
+	b := New("test", NewBuilderOpt{Parameterized: true, Reuse: true}).Select("a").Where("a", "=", 1).Query()
+	first, _, err1 := b.Build()
+	second, args, err2 := b.Build()
+	if err1 != nil || err2 != nil || first != second || fmt.Sprint(args) != "[1]" {
+		t.Fatal("building twice changed the result", first, second, err1, err2)
+	}
+
+	b.Release()
+	defer func() {
+		r := recover()
+		if debugLifecycle && r != ErrReleased {
+			t.Error("expected a panic in debug builds, got", r)
+		}
+	}()
+	if _, _, err := b.Build(); !errors.Is(err, ErrReleased) {
+		t.Error("expected a released error, got", err)
+	}
+	if err := b.Validate(); !errors.Is(err, ErrReleased) {
+		t.Error("expected a released error, got", err)
+	}
+	b.Release()
+}
+
+func TestReleaseChange(t *testing.T) {
+	if !debugLifecycle {
+		t.Skip("only builds tagged sqlbuilder_debug catch changes to a released builder")
+	}
+	b := New("test", NewBuilderOpt{Parameterized: true, Reuse: true}).Select("a").Query()
+	b.Release()
+	defer func() {
+		if r := recover(); r != ErrReleased {
+			t.Error("expected a panic for a change, got", r)
+		}
+	}()
+	b.Where("a", "=", 1)
+}
+
+func TestReleaseNotParameterized(t *testing.T) {
+	db, _ := openFakeDB(t, nil)
+	b := New("test", NewBuilderOpt{Reuse: true}).Where("a", "=", 1).Delete()
+	if _, err := b.ExecContext(context.Background(), db); !errors.Is(err, ErrNotParameterized) {
+		t.Fatal("expected ErrNotParameterized, got", err)
+	}
+	// a failed run leaves the builder to the caller, it is not pooled
+	if sql, _, err := b.Build(); err != nil || sql != "DELETE FROM `test` WHERE `a` = %v" {
+		t.Error("the builder changed after a failed run", sql, err)
+	}
+}
+
+func TestClone(t *testing.T) {
+	opt := NewBuilderOpt{Parameterized: true, Reuse: true}
+	b := New("users u", opt).
+		Select("u.id").
+		Join("orders o", func(w Wheres) Wheres { return w.On("o.user_id", "=", "u.id") }).
+		Where("u.age", ">", 18).
+		WhereIn("u.id", New("vips").Select("user_id").Where("level", ">", 2).Query()).
+		WhereCombineIn([]string{"a", "b"}, [][]interface{}{{1, 2}}).
+		OrderBy("u.id", OrderAsc).
+		Query()
+	want, wantArgs, _ := b.Build()
+
+	c := b.Clone()
+	c.whereList[0].Value[0] = 30
+	c.whereList[1].Value[0].(*SQLBulder).Where("active", "=", true)
+	c.joins[0].On[0].Field = "o.buyer_id"
+	c.orders[0].Order = OrderDesc
+	c.Where("u.name", "=", "jack").Limit(1)
+
+	sql, args, err := b.Build()
+	if err != nil || sql != want || fmt.Sprint(args) != fmt.Sprint(wantArgs) {
+		t.Error("changing the clone changed the builder", sql, args, err)
+	}
+	sql, args, err = c.Build()
+	if err != nil || sql != "SELECT `u`.`id` FROM `users` `u` INNER JOIN `orders` `o` ON `o`.`buyer_id` = `u`.`id` WHERE `u`.`age` > ? AND `u`.`id` in (SELECT `user_id` FROM `vips` WHERE `level` > ? AND `active` = ?) AND (`a`,`b`) in ((?,?)) AND `u`.`name` = ? ORDER BY `u`.`id` DESC LIMIT 1" {
+		t.Error("[clone] wrong sql result", sql, err)
+	}
+	if fmt.Sprint(args) != "[30 2 true 1 2 jack]" {
+		t.Error("[clone] wrong args", args)
+	}
+	c.Release()
+	b.Release()
+
+	u := New("test", opt).Where("id", "=", 1).Update(map[string]interface{}{"a": 1})
+	cu := u.Clone()
+	cu.values[0]["a"] = 2
+	if _, args, _ := u.Build(); fmt.Sprint(args) != "[1 1]" {
+		t.Error("changing the cloned values changed the builder", args)
+	}
+}
+
+func TestImmutable(t *testing.T) {
+	base := New("users", NewBuilderOpt{Parameterized: true, Immutable: true}).
+		Select("id").
+		Where("tenant_id", "=", 7).
+		WhereNull("deleted_at").
+		Query()
+	const want = "SELECT `id` FROM `users` WHERE `tenant_id` = ? AND `deleted_at` is null"
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			variant := base.Where("age", ">", i).Select("name").OrderBy("id", OrderDesc).Limit(int64(i + 1))
+			sql, args, err := variant.Build()
+			if err != nil || sql != "SELECT `id`,`name` FROM `users` WHERE `tenant_id` = ? AND `deleted_at` is null AND `age` > ? ORDER BY `id` DESC LIMIT "+fmt.Sprint(i+1) {
+				t.Error("[variant] wrong sql result", sql, err)
+			}
+			if fmt.Sprint(args) != fmt.Sprintf("[7 %d]", i) {
+				t.Error("[variant] wrong args", args)
+			}
+			if sql, _, _ := base.Build(); sql != want {
+				t.Error("[base] changed by a variant", sql)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// variants of a variant do not overwrite each other either
+	v := base.Where("a", "=", 1)
+	v1, v2 := v.Where("b", "=", 2), v.Where("c", "=", 3)
+	if sql, args, _ := v1.Build(); sql != want+" AND `a` = ? AND `b` = ?" || fmt.Sprint(args) != "[7 1 2]" {
+		t.Error("[v1] wrong sql result", sql, args)
+	}
+	if sql, args, _ := v2.Build(); sql != want+" AND `a` = ? AND `c` = ?" || fmt.Sprint(args) != "[7 1 3]" {
+		t.Error("[v2] wrong sql result", sql, args)
+	}
+
+	ins := New("users", NewBuilderOpt{Parameterized: true, Immutable: true})
+	if sql, _, _ := ins.InsertIgnore(map[string]interface{}{"a": 1}).Build(); sql != "INSERT IGNORE INTO `users` (`a`) VALUES (?)" {
+		t.Error("[insert ignore] wrong sql result", sql)
+	}
+	if sql, _, _ := ins.Insert(map[string]interface{}{"a": 1}).Build(); sql != "INSERT INTO `users` (`a`) VALUES (?)" {
+		t.Error("[insert] changed by insert ignore", sql)
+	}
+	upd := ins.Where("id", "=", 1).Update(map[string]interface{}{"a": 1})
+	if sql, _, _ := upd.Increment("n", 1).Build(); sql != "UPDATE `users` SET `a` = ?,`n` = `n` + ? WHERE `id` = ?" {
+		t.Error("[increment] wrong sql result", sql)
+	}
+	if sql, _, _ := upd.Build(); sql != "UPDATE `users` SET `a` = ? WHERE `id` = ?" {
+		t.Error("[update] changed by increment", sql)
+	}
+}
+
+func TestImmutablePluck(t *testing.T) {
+	db, _ := openFakeDB(t, []string{"name"}, []driver.Value{[]byte("jack")}, []driver.Value{[]byte("rose")})
+	base := New("users", NewBuilderOpt{Parameterized: true, Immutable: true}).
+		Select("id", "name").
+		Where("tenant_id", "=", 7).
+		Query()
+	const want = "SELECT `id`,`name` FROM `users` WHERE `tenant_id` = ?"
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var names []string
+			if err := base.PluckContext(context.Background(), db, "name", &names); err != nil || fmt.Sprint(names) != "[jack rose]" {
+				t.Error("[pluck] wrong values", names, err)
+			}
+			if sql, _, _ := base.Build(); sql != want {
+				t.Error("[base] changed by a pluck", sql)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func benchmarkBuild(b *testing.B, reuse bool) {
+	opt := NewBuilderOpt{Parameterized: true, Reuse: reuse}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		builder := New("users", opt).
+			Select("id", "name").
+			Where("age", ">", 18).
+			WhereIn("status", []int{1, 2, 3}).
+			OrderBy("id", OrderDesc).
+			Limit(10).
+			Query()
+		if _, _, err := builder.Build(); err != nil {
+			b.Fatal(err)
+		}
+		builder.Release()
+	}
+}
+
+func BenchmarkBuildPooled(b *testing.B) {
+	benchmarkBuild(b, true)
+}
+
+func BenchmarkBuildUnpooled(b *testing.B) {
+	benchmarkBuild(b, false)
+}
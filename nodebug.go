@@ -0,0 +1,5 @@
+//go:build !sqlbuilder_debug
+
+package builder
+
+const debugLifecycle = false
@@ -0,0 +1,164 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// OperatorRenderer renders the condition comparing field with the operands,
+// which are already rendered placeholders, columns or subqueries. Field is
+// empty for operators such as exists.
+type OperatorRenderer func(d Dialect, field string, operands []string) (string, error)
+
+// OperatorError is returned by Build for an operator that is not registered.
+type OperatorError struct {
+	Operation Operation
+}
+
+func (e *OperatorError) Error() string {
+	return fmt.Sprintf("%s %q", ErrUnknownOperator, string(e.Operation))
+}
+
+func (e *OperatorError) Is(target error) bool {
+	return target == ErrUnknownOperator
+}
+
+var (
+	operatorsMu sync.RWMutex
+	operators   = map[Operation]OperatorRenderer{}
+)
+
+func init() {
+	for _, op := range []Operation{
+		OpEq, OpNe, "<>", OpLt, OpLte, OpGt, OpGte,
+		OpIn, OpNotIn, OpExists, OpNotExists, OpIsNull, OpIsNotNull,
+	} {
+		RegisterOperator(op, BinaryOperator(string(op)))
+	}
+	RegisterOperator(OpBetween, _renderBetween(OpBetween))
+	RegisterOperator(OpNotBetween, _renderBetween(OpNotBetween))
+	RegisterOperator(OpLike, _renderLike(OpLike))
+	RegisterOperator(OpNotLike, _renderLike(OpNotLike))
+	RegisterOperator(OpRegexp, _renderRegexp)
+	RegisterOperator(OpNullSafeEq, _renderNullSafe(false))
+	RegisterOperator(OpIsNotDistinctFrom, _renderNullSafe(false))
+	RegisterOperator(OpIsDistinctFrom, _renderNullSafe(true))
+}
+
+// RegisterOperator makes op usable in conditions, replacing any operator
+// registered before under the same name. Names are case insensitive.
+func RegisterOperator(op Operation, render OperatorRenderer) {
+	operatorsMu.Lock()
+	defer operatorsMu.Unlock()
+	operators[op.normalize()] = render
+}
+
+func lookupOperator(op Operation) (OperatorRenderer, bool) {
+	operatorsMu.RLock()
+	defer operatorsMu.RUnlock()
+	render, ok := operators[op.normalize()]
+	return render, ok
+}
+
+func (o Operation) normalize() Operation {
+	return Operation(strings.TrimSpace(string(o.lower())))
+}
+
+// BinaryOperator renders `field op operands...`, the form of most operators.
+func BinaryOperator(op string) OperatorRenderer {
+	return func(d Dialect, field string, operands []string) (string, error) {
+		parts := make([]string, 0, 2+len(operands))
+		if field != "" {
+			parts = append(parts, field)
+		}
+		parts = append(parts, op)
+		parts = append(parts, operands...)
+		return strings.Join(parts, " "), nil
+	}
+}
+
+// EscapeLike escapes the wildcards % and _ of s, and the backslash used to
+// escape them, so that s is matched literally by a LIKE pattern.
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// _whereValues returns the values compared by operation, the two bounds of
+// a between can be given as a slice.
+func _whereValues(operation Operation, value interface{}) []interface{} {
+	switch operation.normalize() {
+	case OpBetween, OpNotBetween:
+		if value != nil {
+			return _getInValues([]interface{}{value})
+		}
+	}
+	return []interface{}{value}
+}
+
+// _renderCondition renders the condition comparing field through operation
+// with the already rendered operands. Unknown operators are an error unless
+// allowUnknown is set, then they are rendered as binary operators.
+func _renderCondition(d Dialect, field string, operation Operation, operands []string, allowUnknown bool) (string, error) {
+	if od, ok := d.(OperatorDialect); ok {
+		sql, handled, err := od.RenderOperator(operation.normalize(), field, operands)
+		if handled || err != nil {
+			return sql, err
+		}
+	}
+	render, ok := lookupOperator(operation)
+	if !ok {
+		if !allowUnknown {
+			return "", &OperatorError{Operation: operation}
+		}
+		render = BinaryOperator(string(operation))
+	}
+	return render(d, field, operands)
+}
+
+func _renderBetween(operation Operation) OperatorRenderer {
+	return func(d Dialect, field string, operands []string) (string, error) {
+		if len(operands) != 2 {
+			return "", _wrapError(ErrInvalidExpression, string(operation)+" expects two values")
+		}
+		return field + " " + string(operation) + " " + operands[0] + " and " + operands[1], nil
+	}
+}
+
+func _renderLike(operation Operation) OperatorRenderer {
+	return func(d Dialect, field string, operands []string) (string, error) {
+		res := field + " " + string(operation) + " " + operands[0]
+		// EscapeLike relies on backslash as the escape character
+		if !_features(d).LikeBackslashEscape {
+			res += ` escape '\'`
+		}
+		return res, nil
+	}
+}
+
+func _renderRegexp(d Dialect, field string, operands []string) (string, error) {
+	return field + " " + string(OpRegexp) + " " + operands[0], nil
+}
+
+// _nullSafe reports whether op is a null safe comparison, and whether it
+// holds for distinct values.
+func _nullSafe(op Operation) (distinct, ok bool) {
+	switch op {
+	case OpNullSafeEq, OpIsNotDistinctFrom:
+		return false, true
+	case OpIsDistinctFrom:
+		return true, true
+	}
+	return false, false
+}
+
+func _renderNullSafe(distinct bool) OperatorRenderer {
+	return func(d Dialect, field string, operands []string) (string, error) {
+		if distinct {
+			return field + " " + string(OpIsDistinctFrom) + " " + operands[0], nil
+		}
+		return field + " " + string(OpIsNotDistinctFrom) + " " + operands[0], nil
+	}
+}
@@ -0,0 +1,178 @@
+package builder
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// ScanOne scans the next row of rows into dest, which can be a pointer to a
+// struct, a map[string]interface{} or a value of a single column. It returns
+// sql.ErrNoRows when there is no row left, rows are not closed.
+func ScanOne(rows *sql.Rows, dest interface{}) error {
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return _wrapError(ErrInvalidScanDest, "must be a non-nil pointer")
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	return _scanInto(rows, columns, dv.Elem())
+}
+
+// ScanAll scans every row left in rows into dest, a pointer to a slice of
+// structs, pointers to structs, maps or values of a single column. Rows are
+// not closed.
+func ScanAll(rows *sql.Rows, dest interface{}) error {
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() || dv.Elem().Kind() != reflect.Slice {
+		return _wrapError(ErrInvalidScanDest, "must be a pointer to a slice")
+	}
+	slice := dv.Elem()
+	elemType := slice.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		item := reflect.New(elemType)
+		if err := _scanInto(rows, columns, item.Elem()); err != nil {
+			return err
+		}
+		if isPtr {
+			slice.Set(reflect.Append(slice, item))
+		} else {
+			slice.Set(reflect.Append(slice, item.Elem()))
+		}
+	}
+	return rows.Err()
+}
+
+// _scanInto scans the current row into the addressable value v.
+func _scanInto(rows *sql.Rows, columns []string, v reflect.Value) error {
+	switch {
+	case v.Kind() == reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return _wrapError(ErrInvalidScanDest, "map must have string keys")
+		}
+		if elem := v.Type().Elem(); elem.Kind() != reflect.Interface || elem.NumMethod() != 0 {
+			return _wrapError(ErrInvalidScanDest, "map must have interface{} values, not "+elem.String())
+		}
+		values := make([]interface{}, len(columns))
+		targets := make([]interface{}, len(columns))
+		for i := range values {
+			targets[i] = &values[i]
+		}
+		if err := rows.Scan(targets...); err != nil {
+			return err
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(columns)))
+		}
+		for i, col := range columns {
+			val := values[i]
+			if b, ok := val.([]byte); ok {
+				val = string(b)
+			}
+			if val == nil {
+				v.SetMapIndex(reflect.ValueOf(col), reflect.Zero(v.Type().Elem()))
+			} else {
+				v.SetMapIndex(reflect.ValueOf(col), reflect.ValueOf(val))
+			}
+		}
+		return nil
+	case v.Kind() == reflect.Struct && !_isScannable(v.Type()):
+		plan := getScanPlan(v.Type(), columns)
+		targets := make([]interface{}, len(columns))
+		for i, index := range plan {
+			if index == nil {
+				targets[i] = new(interface{})
+				continue
+			}
+			targets[i] = _fieldAddr(v, index).Interface()
+		}
+		return rows.Scan(targets...)
+	default:
+		if len(columns) != 1 {
+			return _wrapError(ErrInvalidScanDest, "scanning into a single value needs a single column")
+		}
+		return rows.Scan(v.Addr().Interface())
+	}
+}
+
+func _isScannable(t reflect.Type) bool {
+	return t == timeType || reflect.PtrTo(t).Implements(scannerType)
+}
+
+// _fieldAddr returns the address of the field at index, allocating the nil
+// embedded struct pointers on the way.
+func _fieldAddr(v reflect.Value, index []int) reflect.Value {
+	for i, idx := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(idx)
+	}
+	return v.Addr()
+}
+
+type scanPlanKey struct {
+	t       reflect.Type
+	columns string
+}
+
+var scanPlans sync.Map
+
+// getScanPlan returns the field index for every column of a result scanned
+// into struct type t, nil for the columns without a field. Plans are cached
+// per type and column list.
+func getScanPlan(t reflect.Type, columns []string) [][]int {
+	key := scanPlanKey{t: t, columns: strings.Join(columns, ",")}
+	if plan, ok := scanPlans.Load(key); ok {
+		return plan.([][]int)
+	}
+	info := getStructInfo(t)
+	byColumn := make(map[string][]int, len(info.fields))
+	for _, f := range info.fields {
+		if _, ok := byColumn[f.column]; !ok {
+			byColumn[f.column] = f.index
+		}
+	}
+	plan := make([][]int, len(columns))
+	for i, col := range columns {
+		if index, ok := byColumn[col]; ok {
+			plan[i] = index
+			continue
+		}
+		// columns selected as table.column without an alias
+		if dot := strings.LastIndexByte(col, '.'); dot != -1 {
+			plan[i] = byColumn[col[dot+1:]]
+		}
+		if plan[i] == nil {
+			plan[i] = byColumn[strings.ToLower(col)]
+		}
+	}
+	scanPlans.Store(key, plan)
+	return plan
+}
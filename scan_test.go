@@ -0,0 +1,106 @@
+package builder
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type TestAudit struct {
+	Remark sql.NullString `db:"remark"`
+}
+
+type testOrder struct {
+	ID       int64   `db:"id"`
+	UserName string  `db:"user_name"`
+	Amount   float64 `db:"amount"`
+	Note     *string `db:"note"`
+	*TestAudit
+}
+
+func TestScan(t *testing.T) {
+	ctx := context.Background()
+	columns := []string{"id", "user_name", "amount", "note", "remark", "ignored"}
+	db, fake := openFakeDB(t, columns,
+		[]driver.Value{int64(1), []byte("jack"), 9.5, nil, "vip", int64(0)},
+		[]driver.Value{int64(2), []byte("rose"), 3.0, []byte("gift"), nil, int64(0)},
+	)
+
+	var order testOrder
+	err := New("orders o").
+		Select("o.id", "u.name AS user_name", "o.amount", "o.note", "o.remark", "o.ignored").
+		Join("users u", func(w Wheres) Wheres { return w.On("u.id", "=", "o.user_id") }).
+		Query().
+		GetContext(ctx, db, &order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.ID != 1 || order.UserName != "jack" || order.Amount != 9.5 || order.Note != nil ||
+		order.TestAudit == nil || order.Remark.String != "vip" {
+		t.Errorf("[get] wrong struct %+v", order)
+	}
+
+	var orders []*testOrder
+	if err := New("orders").Select("*").Query().SelectContext(ctx, db, &orders); err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 2 || orders[1].UserName != "rose" || orders[1].Note == nil || *orders[1].Note != "gift" ||
+		orders[1].Remark.Valid {
+		t.Errorf("[select] wrong structs %+v", orders)
+	}
+
+	var values []testOrder
+	if err := New("orders").Select("*").Query().SelectContext(ctx, db, &values); err != nil || len(values) != 2 {
+		t.Errorf("[select] wrong structs %+v %v", values, err)
+	}
+
+	var rows []map[string]interface{}
+	if err := New("orders").Select("*").Query().SelectContext(ctx, db, &rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0]["user_name"] != "jack" || rows[0]["note"] != nil || rows[1]["id"] != int64(2) {
+		t.Errorf("[select] wrong maps %v", rows)
+	}
+
+	row := map[string]interface{}{}
+	if err := New("orders").Select("*").Query().GetContext(ctx, db, &row); err != nil || row["remark"] != "vip" {
+		t.Errorf("[get] wrong map %v %v", row, err)
+	}
+
+	fake.columns = []string{"user_name"}
+	fake.rows = [][]driver.Value{{[]byte("jack")}, {[]byte("rose")}}
+	var names []string
+	if err := New("orders").PluckContext(ctx, db, "user_name", &names); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(names) != "[jack rose]" {
+		t.Error("[pluck] wrong values", names)
+	}
+	if last := fake.queries[len(fake.queries)-1]; last != "SELECT `user_name` FROM `orders`" {
+		t.Error("[pluck] wrong sql", last)
+	}
+
+	var id int64
+	if err := New("orders").Select("id").Query().GetContext(ctx, db, id); !errors.Is(err, ErrInvalidScanDest) {
+		t.Error("[get] expected ErrInvalidScanDest for a non pointer, got", err)
+	}
+	typed := map[string]string{}
+	if err := New("orders").Select("*").Query().GetContext(ctx, db, &typed); !errors.Is(err, ErrInvalidScanDest) {
+		t.Error("[get] expected ErrInvalidScanDest for a typed map, got", err)
+	}
+}
+
+func TestScanPlanCache(t *testing.T) {
+	typ := reflect.TypeOf(testOrder{})
+	first := getScanPlan(typ, []string{"o.id", "USER_NAME", "missing"})
+	if fmt.Sprint(first) != "[[0] [1] []]" {
+		t.Error("wrong scan plan", first)
+	}
+	if second := getScanPlan(typ, []string{"o.id", "USER_NAME", "missing"}); &second[0] != &first[0] {
+		t.Error("scan plan is not cached")
+	}
+}
@@ -0,0 +1,231 @@
+package builder
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// structField maps a struct field to a column through its `db` tag, written
+// as `db:"name,omitempty,readonly,pk"`. A tag of "-" skips the field.
+type structField struct {
+	column    string
+	index     []int
+	omitempty bool
+	readonly  bool
+	pk        bool
+}
+
+type structInfo struct {
+	fields []*structField
+}
+
+func (info *structInfo) columns() []string {
+	columns := make([]string, 0, len(info.fields))
+	for _, f := range info.fields {
+		columns = append(columns, f.column)
+	}
+	return columns
+}
+
+var structInfos sync.Map
+
+// getStructInfo returns the column mapping of struct type t, it is computed
+// once per type.
+func getStructInfo(t reflect.Type) *structInfo {
+	if info, ok := structInfos.Load(t); ok {
+		return info.(*structInfo)
+	}
+	info := &structInfo{fields: _structFields(t, nil)}
+	actual, _ := structInfos.LoadOrStore(t, info)
+	return actual.(*structInfo)
+}
+
+func _structFields(t reflect.Type, index []int) []*structField {
+	fields := make([]*structField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("db")
+		if tag == "-" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		fieldIndex := append(append(make([]int, 0, len(index)+1), index...), i)
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && opts[0] == "" && ft.Kind() == reflect.Struct {
+			// like encoding/json, pointers to unexported structs can not
+			// be allocated so they are skipped
+			if sf.PkgPath != "" && sf.Type.Kind() == reflect.Ptr {
+				continue
+			}
+			fields = append(fields, _structFields(ft, fieldIndex)...)
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		f := &structField{
+			column: opts[0],
+			index:  fieldIndex,
+		}
+		if f.column == "" {
+			f.column = _snakeCase(sf.Name)
+		}
+		for _, opt := range opts[1:] {
+			switch opt {
+			case "omitempty":
+				f.omitempty = true
+			case "readonly":
+				f.readonly = true
+			case "pk":
+				f.pk = true
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// _structValue returns the struct held by v, dereferencing pointers.
+func _structValue(v interface{}) (reflect.Value, bool) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return rv, false
+		}
+		rv = rv.Elem()
+	}
+	return rv, rv.Kind() == reflect.Struct
+}
+
+// _fieldValue returns the value of the field at index, nil when the field or
+// an embedded struct on the way is a nil pointer.
+func _fieldValue(rv reflect.Value, index []int) (reflect.Value, bool) {
+	for i, idx := range index {
+		if i > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return rv, false
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(idx)
+	}
+	return rv, true
+}
+
+// _structRow converts rv to a row of values. Readonly fields are always left
+// out, pk fields only when pk is false, and zero omitempty fields when
+// omitempty is true.
+func _structRow(info *structInfo, rv reflect.Value, pk, omitempty bool) map[string]interface{} {
+	row := make(map[string]interface{}, len(info.fields))
+	for _, f := range info.fields {
+		if f.readonly || (f.pk && !pk) {
+			continue
+		}
+		fv, ok := _fieldValue(rv, f.index)
+		if !ok {
+			continue
+		}
+		if omitempty && f.omitempty && fv.IsZero() {
+			continue
+		}
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				row[f.column] = nil
+				continue
+			}
+			fv = fv.Elem()
+		}
+		row[f.column] = fv.Interface()
+	}
+	return row
+}
+
+// InsertStruct inserts the struct v, or a pointer to it, using the columns
+// from its `db` tags.
+func (builder *SQLBulder) InsertStruct(v interface{}) *SQLBulder {
+	builder = builder.derive()
+	rv, ok := _structValue(v)
+	if !ok {
+		return builder.setErr(_wrapError(ErrNotStruct, "InsertStruct expects a struct"))
+	}
+	info := getStructInfo(rv.Type())
+	builder.columns = info.columns()
+	return builder.Insert(_structRow(info, rv, true, true))
+}
+
+// BatchInsertStruct inserts every struct of the slice v. Since all rows must
+// have the same columns, omitempty is not applied.
+func (builder *SQLBulder) BatchInsertStruct(v interface{}) *SQLBulder {
+	builder = builder.derive()
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Slice {
+		return builder.setErr(_wrapError(ErrNotStruct, "BatchInsertStruct expects a slice of structs"))
+	}
+	values := make([]map[string]interface{}, 0, rv.Len())
+	var info *structInfo
+	for i := 0; i < rv.Len(); i++ {
+		item, ok := _structValue(rv.Index(i).Interface())
+		if !ok {
+			return builder.setErr(_wrapError(ErrNotStruct, "BatchInsertStruct expects a slice of structs"))
+		}
+		if info == nil {
+			info = getStructInfo(item.Type())
+			builder.columns = info.columns()
+		}
+		values = append(values, _structRow(info, item, true, false))
+	}
+	return builder.BatchInsert(values)
+}
+
+// UpdateStruct updates the row identified by the pk fields of the struct v
+// with its other fields.
+func (builder *SQLBulder) UpdateStruct(v interface{}) *SQLBulder {
+	builder = builder.derive()
+	rv, ok := _structValue(v)
+	if !ok {
+		return builder.setErr(_wrapError(ErrNotStruct, "UpdateStruct expects a struct"))
+	}
+	info := getStructInfo(rv.Type())
+	for _, f := range info.fields {
+		if !f.pk {
+			continue
+		}
+		fv, ok := _fieldValue(rv, f.index)
+		// a zero pk would update no row, or the wrong one. The values are
+		// still set so that Validate only reports the pk.
+		if !ok || fv.IsZero() {
+			builder = builder.setErr(_wrapError(ErrNoWhere, "UpdateStruct pk "+f.column+" is zero"))
+			continue
+		}
+		builder = builder.Where(f.column, OpEq, fv.Interface())
+	}
+	builder.columns = info.columns()
+	return builder.Update(_structRow(info, rv, false, true))
+}
+
+// _snakeCase converts a go field name like UserID to user_id.
+func _snakeCase(name string) string {
+	runes := []rune(name)
+	sb := strings.Builder{}
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				sb.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,88 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type testTimestamps struct {
+	CreatedAt string `db:"created_at,readonly"`
+	UpdatedAt string
+}
+
+type testUser struct {
+	ID       int64  `db:"id,pk,omitempty"`
+	Name     string `db:"name"`
+	Nickname string `db:"nick,omitempty"`
+	Age      *int   `db:"age"`
+	Secret   string `db:"-"`
+	internal string
+	testTimestamps
+}
+
+func TestSQLBuildStruct(t *testing.T) {
+	opt := NewBuilderOpt{Parameterized: true, Reuse: true}
+	age := 18
+
+	sql, args, err := New("users", opt).InsertStruct(&testUser{
+		Name:           "jack",
+		Age:            &age,
+		Secret:         "x",
+		testTimestamps: testTimestamps{CreatedAt: "now", UpdatedAt: "today"},
+	}).Build()
+	if err != nil || sql != "INSERT INTO `users` (`name`,`age`,`updated_at`) VALUES (?,?,?)" {
+		t.Error("[insert] wrong sql result", sql, err)
+	}
+	if fmt.Sprint(args) != "[jack 18 today]" {
+		t.Error("[insert] wrong args", args)
+	}
+
+	sql, args, err = New("users", opt).BatchInsertStruct([]testUser{
+		{ID: 1, Name: "jack", Age: &age},
+		{ID: 2, Name: "rose", Nickname: "r", Age: &age},
+	}).Build()
+	if err != nil || sql != "INSERT INTO `users` (`id`,`name`,`nick`,`age`,`updated_at`) VALUES (?,?,?,?,?),(?,?,?,?,?)" {
+		t.Error("[batch insert] wrong sql result", sql, err)
+	}
+	if fmt.Sprint(args) != "[1 jack  18  2 rose r 18 ]" {
+		t.Error("[batch insert] wrong args", args)
+	}
+
+	sql, args, err = New("users", opt).UpdateStruct(testUser{
+		ID:   7,
+		Name: "rose",
+		Age:  &age,
+	}).Build()
+	if err != nil || sql != "UPDATE `users` SET `name` = ?,`age` = ?,`updated_at` = ? WHERE `id` = ?" {
+		t.Error("[update] wrong sql result", sql, err)
+	}
+	if fmt.Sprint(args) != "[rose 18  7]" {
+		t.Error("[update] wrong args", args)
+	}
+
+	if _, _, err := New("users", opt).InsertStruct(1).Build(); err == nil {
+		t.Error("[insert] expected an error for a non struct value")
+	}
+
+	if _, _, err := New("users", opt).UpdateStruct(testUser{Name: "rose"}).Build(); !errors.Is(err, ErrNoWhere) {
+		t.Error("[update] expected ErrNoWhere for a zero pk, got", err)
+	}
+	if err := New("users", opt).UpdateStruct(testUser{Name: "rose"}).Validate(); err == nil || err.Error() != "invalid sql: can not update or delete without where conditions: UpdateStruct pk id is zero" {
+		t.Error("[update] expected a single problem for a zero pk, got", err)
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for name, want := range map[string]string{
+		"ID":         "id",
+		"UserID":     "user_id",
+		"CreatedAt":  "created_at",
+		"HTTPServer": "http_server",
+		"name":       "name",
+	} {
+		if got := _snakeCase(name); got != want {
+			t.Errorf("_snakeCase(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package builder
+
+import "errors"
+
+// Validate checks the builder like Build does, but reports every problem
+// found instead of the first one, as a *ValidationError. The builder is not
+// released, so it can be built afterwards.
+func (builder *SQLBulder) Validate() error {
+	if err := builder.checkReleased(); err != nil {
+		return err
+	}
+	errs := append([]error(nil), builder.errs...)
+	st := builder.newStmt()
+	add := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	switch {
+	case builder.tableName == "":
+		add(ErrEmptyTable)
+	case builder.fromSub != nil:
+		_, err := quoteIdent(st.dialect, builder.tableName)
+		add(err)
+		add(builder.fromSub.Validate())
+	default:
+		_, err := quoteTable(st.dialect, builder.tableName)
+		add(err)
+	}
+
+	// a missing where may be recorded already, e.g. by UpdateStruct
+	noWhere := func() {
+		if len(builder.whereList) > 0 {
+			return
+		}
+		for _, err := range builder.errs {
+			if errors.Is(err, ErrNoWhere) {
+				return
+			}
+		}
+		add(ErrNoWhere)
+	}
+
+	switch builder.action {
+	case SQLActionInsert:
+		if !builder.hasValues() {
+			add(ErrNoValues)
+			break
+		}
+		if builder.insert.batchMode == BatchStrict {
+			add(_checkBatchColumns(builder.values))
+		}
+		errs = append(errs, builder.validateValues(st)...)
+		conflict := builder.insert.conflict
+		for _, keys := range [][]string{builder.insert.onDupKeyUpdates, conflict.columns} {
+			for _, key := range keys {
+				_, err := quoteIdent(st.dialect, key)
+				add(err)
+			}
+		}
+		st.upsert = true
+		errs = append(errs, conflict.where.validate(st)...)
+		st.upsert = false
+	case SQLActionUpdate:
+		if !builder.hasValues() {
+			add(ErrNoValues)
+		}
+		noWhere()
+		errs = append(errs, builder.validateValues(st)...)
+	case SQLActionDelete:
+		noWhere()
+	case SQLActionSelect:
+		errs = append(errs, builder.validateSelect(st)...)
+	default:
+		add(ErrUnknownAction)
+	}
+
+	for _, j := range builder.joins {
+		_, err := quoteTable(st.dialect, j.Table)
+		add(err)
+		errs = append(errs, j.On.validate(st)...)
+	}
+	errs = append(errs, builder.whereList.validate(st)...)
+
+	// whatever the checks above miss, such as a feature the dialect does
+	// not support, still fails the build
+	if len(errs) == 0 {
+		_, err := builder.statements()
+		add(err)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errs: errs}
+}
+
+// validateValues checks the columns and values written by an insert or an
+// update.
+func (builder *SQLBulder) validateValues(st *stmt) []error {
+	errs := make([]error, 0)
+	for _, row := range builder.values {
+		for col, value := range row {
+			if _, err := quoteName(st.dialect, col); err != nil {
+				errs = append(errs, err)
+			}
+			if e, ok := value.(Expr); ok {
+				if _, _, err := st.bind(e); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+		// later rows of a batch have the same columns, or a different
+		// problem reported already
+		if len(errs) > 0 {
+			break
+		}
+	}
+	return errs
+}
+
+func (builder *SQLBulder) validateSelect(st *stmt) []error {
+	errs := make([]error, 0)
+	add := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, cte := range builder.ctes {
+		_, err := quoteIdent(st.dialect, cte.Name)
+		add(err)
+		add(cte.Query.Validate())
+		if cte.Recursive != nil {
+			add(cte.Recursive.Validate())
+		}
+	}
+	for _, field := range builder.fields {
+		_, _, err := st.field(field)
+		add(err)
+	}
+	for _, group := range builder.groups {
+		_, err := st.expr(group)
+		add(err)
+	}
+	errs = append(errs, builder.havingList.validate(st)...)
+	for _, w := range builder.windows {
+		_, err := w.window.string(st)
+		add(err)
+	}
+	for _, c := range builder.compounds {
+		add(c.Query.Validate())
+	}
+	for _, o := range builder.orders {
+		_, _, err := o.string(st)
+		add(err)
+	}
+	if builder.forceIndexName != "" {
+		_, err := quoteIdent(st.dialect, string(builder.forceIndexName))
+		add(err)
+	}
+	if builder.lock != "" {
+		_, err := st.dialect.Lock(builder.lock)
+		add(err)
+	}
+	return errs
+}
+
+// validate renders every condition on its own, so that each one with a
+// problem is reported.
+func (whs Wheres) validate(st *stmt) []error {
+	errs := make([]error, 0)
+	for _, wh := range whs {
+		switch {
+		case len(wh.Children) > 0:
+			errs = append(errs, wh.Children.validate(st)...)
+		case wh.CombineLeft != nil:
+			// rendered with the head of its combination
+		default:
+			if _, _, err := wh.string(true, false, st); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,202 @@
+package builder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type FrameUnit string
+
+const (
+	FrameRows  FrameUnit = "ROWS"
+	FrameRange FrameUnit = "RANGE"
+)
+
+type FrameBound string
+
+const (
+	UnboundedPreceding FrameBound = "UNBOUNDED PRECEDING"
+	CurrentRow         FrameBound = "CURRENT ROW"
+	UnboundedFollowing FrameBound = "UNBOUNDED FOLLOWING"
+)
+
+func Preceding(n int64) FrameBound {
+	return FrameBound(strconv.FormatInt(n, 10) + " PRECEDING")
+}
+
+func Following(n int64) FrameBound {
+	return FrameBound(strconv.FormatInt(n, 10) + " FOLLOWING")
+}
+
+func (b FrameBound) isOffset() bool {
+	return b != UnboundedPreceding && b != CurrentRow && b != UnboundedFollowing
+}
+
+type Frame struct {
+	Unit  FrameUnit
+	Start FrameBound
+	End   FrameBound
+}
+
+func (f *Frame) String() string {
+	return string(f.Unit) + " BETWEEN " + string(f.Start) + " AND " + string(f.End)
+}
+
+// Window is the specification of a window function. Base names a window
+// defined in the WINDOW clause which the rest of the specification extends.
+type Window struct {
+	Base       string
+	Partitions []string
+	Orders     Orders
+	Frame      *Frame
+}
+
+func NewWindow() *Window {
+	return new(Window)
+}
+
+// WindowRef refers to the window name defined in the WINDOW clause.
+func WindowRef(name string) *Window {
+	return &Window{Base: name}
+}
+
+func (w *Window) PartitionBy(fields ...string) *Window {
+	w.Partitions = append(w.Partitions, fields...)
+	return w
+}
+
+func (w *Window) OrderBy(field string, order OrderEnum) *Window {
+	w.Orders = append(w.Orders, &Order{
+		Field: field,
+		Order: order,
+	})
+	return w
+}
+
+func (w *Window) Rows(start, end FrameBound) *Window {
+	w.Frame = &Frame{Unit: FrameRows, Start: start, End: end}
+	return w
+}
+
+func (w *Window) Range(start, end FrameBound) *Window {
+	w.Frame = &Frame{Unit: FrameRange, Start: start, End: end}
+	return w
+}
+
+func (w *Window) isRef() bool {
+	return w.Base != "" && len(w.Partitions) == 0 && len(w.Orders) == 0 && w.Frame == nil
+}
+
+func (w *Window) string(st *stmt) (string, error) {
+	if w.Frame != nil && w.Frame.Unit == FrameRange && _features(st.dialect).NoRangeOffsets &&
+		(w.Frame.Start.isOffset() || w.Frame.End.isOffset()) {
+		return "", _wrapError(ErrUnsupported, st.dialect.Name()+" only supports unbounded and current row bounds in RANGE frames")
+	}
+	parts := make([]string, 0, 4)
+	if w.Base != "" {
+		base, err := quoteIdent(st.dialect, w.Base)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, base)
+	}
+	if len(w.Partitions) > 0 {
+		partitions := make([]string, 0, len(w.Partitions))
+		for _, p := range w.Partitions {
+			partition, err := quoteColumn(st.dialect, p, false)
+			if err != nil {
+				return "", err
+			}
+			partitions = append(partitions, partition)
+		}
+		parts = append(parts, "PARTITION BY "+strings.Join(partitions, ","))
+	}
+	if len(w.Orders) > 0 {
+		orders, args, err := w.Orders.string(st)
+		if err != nil {
+			return "", err
+		}
+		if len(args) > 0 {
+			return "", _wrapError(ErrInvalidExpression, "window orders can not bind args")
+		}
+		parts = append(parts, orders)
+	}
+	if w.Frame != nil {
+		parts = append(parts, w.Frame.String())
+	}
+	return _wrapBracket(strings.Join(parts, " ")), nil
+}
+
+// WindowFunc is a window function call in the select list, such as
+// ROW_NUMBER() OVER (PARTITION BY a ORDER BY b) AS rn.
+type WindowFunc struct {
+	// Func is a function called with a single column or number, such as
+	// ROW_NUMBER() or LAG(amount), which is validated and quoted.
+	Func  string
+	Over  *Window
+	Alias string
+	// raw renders Func verbatim, see SelectOverRaw
+	raw bool
+}
+
+func (f *WindowFunc) string(st *stmt) (string, error) {
+	var over string
+	var err error
+	switch {
+	case f.Over == nil:
+		// the whole result is the window
+		over = "()"
+	case f.Over.isRef():
+		over, err = quoteIdent(st.dialect, f.Over.Base)
+	default:
+		over, err = f.Over.string(st)
+	}
+	if err != nil {
+		return "", err
+	}
+	fn := f.Func
+	if !f.raw {
+		if !funcRe.MatchString(strings.TrimSpace(fn)) {
+			return "", _wrapError(ErrInvalidExpression, fmt.Sprintf("%q is not a window function call", fn))
+		}
+		if fn, err = quoteColumn(st.dialect, fn, false); err != nil {
+			return "", err
+		}
+	}
+	res := fn + " OVER " + over
+	if f.Alias != "" {
+		alias, err := quoteIdent(st.dialect, f.Alias)
+		if err != nil {
+			return "", err
+		}
+		res += " AS " + alias
+	}
+	return res, nil
+}
+
+type namedWindow struct {
+	name   string
+	window *Window
+}
+
+type namedWindows []*namedWindow
+
+func (windows namedWindows) string(st *stmt) (string, error) {
+	if len(windows) == 0 {
+		return "", nil
+	}
+	resList := make([]string, 0, len(windows))
+	for _, w := range windows {
+		spec, err := w.window.string(st)
+		if err != nil {
+			return "", err
+		}
+		name, err := quoteIdent(st.dialect, w.name)
+		if err != nil {
+			return "", err
+		}
+		resList = append(resList, name+" AS "+spec)
+	}
+	return "WINDOW " + strings.Join(resList, ", "), nil
+}